@@ -1,23 +1,41 @@
 package main
 
 import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/bus/memory"
+	natsbus "GatewayService/internal/bus/nats"
+	"GatewayService/internal/cache"
+	"GatewayService/internal/cleanup"
 	"GatewayService/internal/config"
+	"GatewayService/internal/events"
+	"GatewayService/internal/grpcapi"
 	"GatewayService/internal/handler"
 	"GatewayService/internal/handler/mapper"
 	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/idempotency"
+	"GatewayService/internal/jwtauth"
 	"GatewayService/internal/middleware"
+	"GatewayService/internal/operation"
+	"GatewayService/internal/outbox"
+	"GatewayService/internal/password"
 	"GatewayService/internal/provider"
+	"GatewayService/internal/rabbit"
+	"GatewayService/internal/refresh"
 	"GatewayService/internal/repository"
+	"GatewayService/internal/revocation"
 	"GatewayService/internal/server"
 	"GatewayService/internal/service"
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/go-playground/validator/v10"
-	"github.com/streadway/amqp"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func main() {
@@ -57,49 +75,363 @@ func main() {
 		).Panic("failed to connect to auth provider")
 	}
 
-	rabbitConnection, err := initRabbitMQConnection(cfg)
-	if err != nil {
+	var (
+		messageBus  bus.MessageBus
+		brokerState middleware.BrokerState
+		outboxStats handler.OutboxStatsSource
+		// set when the in-memory bus stands in for the broker
+		memoryBus *memory.Bus
+		// writes are spooled instead of refused while the broker is down
+		spooling bool
+		// started once the server is about to run
+		background []func(ctx context.Context)
+	)
+
+	busCfg := cfg.GetBusConfig()
+
+	switch busCfg.Kind {
+	case bus.Memory:
+		memoryBus = memory.NewBus(logger)
+
+		logger.Warn("Using the in-memory message bus, store actions are served by an in-memory storage")
+
+		messageBus = memoryBus
+		brokerState = memoryBus
+	case bus.NATS:
+		natsCfg := cfg.GetNATSConfig()
+
+		natsBus, err := natsbus.NewBus(*natsCfg, logger)
+		if err != nil {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Panic("Failed to connect to NATS")
+		}
+		defer natsBus.Close()
+
+		messageBus = natsBus
+		brokerState = natsBus
+	case "", bus.RabbitMQ:
+		mqConfig, err := cfg.GetRabbitMQConfig()
+		if err != nil {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Panic("Failed to read RabbitMQ config")
+		}
+
+		err = rabbit.ValidateTopology(mqConfig.Topology)
+		if err != nil {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Panic("Invalid RabbitMQ topology")
+		}
+
+		messageRouter, err := rabbit.NewRouter(mqConfig.Routing, mqConfig.Queue)
+		if err != nil {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Panic("Invalid RabbitMQ routing")
+		}
+
+		connectionManager := rabbit.NewConnectionManager(cfg.GetAMQPConnectionURL(mqConfig),
+			mqConfig.ReconnectBackoff, mqConfig.ReconnectMaxBackoff, logger)
+
+		publisher := rabbit.NewPublisher(mqConfig.PublisherChannels, mqConfig.ConfirmTimeout, mqConfig.PublisherWaitTimeout, logger)
+
+		replyConsumer := rabbit.NewReplyConsumer(logger)
+
+		// topology goes first so the publisher and consumer find their queues
+		connectionManager.OnConnect(rabbit.DeclareTopology(mqConfig.Topology, logger))
+		connectionManager.OnConnect(publisher.Attach)
+		connectionManager.OnConnect(replyConsumer.Attach)
+
+		// an unreachable broker is not fatal, Run keeps dialing while the
+		// outbox spools and the reads answer 503. A topology mismatch is a
+		// config error and stops the gateway
+		err = connectionManager.Connect()
+		var topologyErr *rabbit.TopologyError
+		if errors.As(err, &topologyErr) {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Panic("Failed to establish RabbitMQ Connection")
+		} else if err != nil {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Warn("RabbitMQ is unavailable, starting disconnected")
+		}
+
+		var storesPublisher rabbit.MessagePublisher = publisher
+
+		outboxCfg := cfg.GetOutboxConfig()
+
+		if outboxCfg.Enabled {
+			spool, err := outbox.OpenSpool(outboxCfg.Dir, outboxCfg.MaxBytes)
+			if err != nil {
+				logger.With(
+					zap.String("place", "main"),
+					zap.Error(err),
+				).Panic("Failed to open outbox spool")
+			}
+			defer spool.Close()
+
+			messageOutbox := outbox.NewOutbox(spool, publisher, connectionManager, outboxCfg.RetryInterval, logger)
+			storesPublisher = messageOutbox
+			outboxStats = messageOutbox
+			spooling = true
+			background = append(background, messageOutbox.Run)
+		}
+
+		messageBus = rabbit.NewBus(storesPublisher, messageRouter, replyConsumer, connectionManager, logger)
+		brokerState = connectionManager
+		background = append(background, connectionManager.Run)
+	default:
 		logger.With(
 			zap.String("place", "main"),
-			zap.Error(err),
-		).Panic("Failed to establish RabbitMQ Connection")
+			zap.String("kind", busCfg.Kind),
+		).Panic("Unknown message bus kind")
+	}
+
+	cacheCfg := cfg.GetCacheConfig()
+
+	var (
+		cacheMaxAge      time.Duration
+		storeInvalidator handler.StoreInvalidator
+	)
+	if cacheCfg.Enabled {
+		var storeCache cache.Cache
+
+		switch cacheCfg.Backend {
+		case "", cache.MemoryBackend:
+			storeCache = cache.NewLRU(cacheCfg.MaxEntries, cacheCfg.TTL)
+		case cache.RedisBackend:
+			redisCache := cache.NewRedis(redis.NewClient(&redis.Options{
+				Addr:     cacheCfg.Redis.Addr,
+				Password: cacheCfg.Redis.Password,
+				DB:       cacheCfg.Redis.DB,
+			}), cacheCfg.Redis.KeyPrefix, cacheCfg.TTL)
+			defer redisCache.Close()
+
+			storeCache = redisCache
+		default:
+			logger.With(
+				zap.String("place", "main"),
+				zap.String("backend", cacheCfg.Backend),
+			).Panic("Unknown cache backend")
+		}
+
+		cacheBus := cache.NewBus(messageBus, storeCache, logger)
+		messageBus = cacheBus
+		storeInvalidator = cacheBus
+		cacheMaxAge = cacheCfg.TTL
+	}
+
+	userRepository := repository.NewMockUserRepository()
+
+	refreshCfg := cfg.GetRefreshConfig()
+
+	var refreshTokens refresh.Store
+
+	switch refreshCfg.Store {
+	case "", refresh.MemoryStore:
+		mustCheckInterval(logger, "refresh.cleanupInterval", refreshCfg.CleanupInterval)
+
+		memoryTokens := refresh.NewMemory()
+		background = append(background, func(ctx context.Context) {
+			memoryTokens.Run(ctx, refreshCfg.CleanupInterval)
+		})
+
+		refreshTokens = memoryTokens
+	case refresh.RedisStore:
+		redisTokens := refresh.NewRedis(redis.NewClient(&redis.Options{
+			Addr:     refreshCfg.Redis.Addr,
+			Password: refreshCfg.Redis.Password,
+			DB:       refreshCfg.Redis.DB,
+		}), refreshCfg.Redis.KeyPrefix)
+		defer redisTokens.Close()
+
+		refreshTokens = redisTokens
+	default:
+		logger.With(
+			zap.String("place", "main"),
+			zap.String("store", refreshCfg.Store),
+		).Panic("Unknown refresh token store")
 	}
 
-	channel, err := initRabbitChannel(rabbitConnection)
+	revocationCfg := cfg.GetRevocationConfig()
+
+	var revocations revocation.Store
+
+	switch revocationCfg.Store {
+	case "", revocation.MemoryStore:
+		mustCheckInterval(logger, "revocation.cleanupInterval", revocationCfg.CleanupInterval)
+
+		memoryRevocations := revocation.NewMemory()
+		background = append(background, func(ctx context.Context) {
+			memoryRevocations.Run(ctx, revocationCfg.CleanupInterval)
+		})
+
+		revocations = memoryRevocations
+	case revocation.RedisStore:
+		redisRevocations := revocation.NewRedis(redis.NewClient(&redis.Options{
+			Addr:     revocationCfg.Redis.Addr,
+			Password: revocationCfg.Redis.Password,
+			DB:       revocationCfg.Redis.DB,
+		}), revocationCfg.Redis.KeyPrefix)
+		defer redisRevocations.Close()
+
+		revocations = redisRevocations
+	default:
+		logger.With(
+			zap.String("place", "main"),
+			zap.String("store", revocationCfg.Store),
+		).Panic("Unknown revocation store")
+	}
+
+	// revoking a login revokes its refresh tokens too, they have to expire first
+	denylist := revocation.NewDenylist(revocations, max(revocationCfg.Retention, refreshCfg.TTL))
+
+	passwords, err := password.NewHasher(*cfg.GetPasswordConfig())
 	if err != nil {
 		logger.With(
 			zap.String("place", "main"),
 			zap.Error(err),
-		).Panic("Failed to init RabbitMQ channel")
+		).Panic("invalid password hashing config")
+	}
+
+	authService := service.NewAuthService(authProvider, logger, userRepository, passwords, refreshTokens, refreshCfg.TTL, denylist)
+
+	errorMapper := mapper.NewAuthErrorMapper()
+
+	authHandler := handler.NewAuthHandler(authService, logger, errorMapper)
+
+	operationsCfg := cfg.GetOperationsConfig()
+
+	mustCheckInterval(logger, "operations.cleanupInterval", operationsCfg.CleanupInterval)
+
+	if operationsCfg.CallbackSecret == "" {
+		logger.With(
+			zap.String("place", "main"),
+		).Panic("operations.callbackSecret must be set, the storage service callbacks are checked against it")
+	}
+
+	operationTracker := operation.NewTracker(operationsCfg.Retention)
+
+	idempotencyCfg := cfg.GetIdempotencyConfig()
+
+	mustCheckInterval(logger, "idempotency.cleanupInterval", idempotencyCfg.CleanupInterval)
+
+	idempotencyStore := idempotency.NewStore(idempotencyCfg.Window)
+
+	eventsCfg := cfg.GetEventsConfig()
+
+	mustCheckInterval(logger, "events.heartbeatInterval", eventsCfg.HeartbeatInterval)
+	mustCheckInterval(logger, "events.cleanupInterval", eventsCfg.CleanupInterval)
+
+	eventHub := events.NewHub(eventsCfg.HistorySize, eventsCfg.HistoryRetention, eventsCfg.BufferSize)
+
+	storesService := service.NewStoresService(messageBus, busCfg.ReplyTimeout, operationTracker, logger)
+
+	storesHandler := handler.NewStoresHandler(storesService, cacheMaxAge, storeInvalidator, operationTracker, eventHub, logger, structValidator, mapper.NewStoresErrorMapper())
+
+	if memoryBus != nil {
+		memoryStorage := memory.NewStorage(func(operationId string, data json.RawMessage, errMessage string) {
+			_, _ = storesHandler.CompleteOperation(handler.OperationResult{
+				OperationID: operationId,
+				Error:       errMessage,
+				Data:        data,
+			})
+		}, logger)
+
+		if err := memoryStorage.Register(memoryBus); err != nil {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Panic("Failed to register the in-memory storage")
+		}
 	}
 
-	queueName, err := declareRabbitQueue(channel)
+	operationsHandler := handler.NewOperationsHandler(operationTracker, logger)
+
+	eventsHandler := handler.NewEventsHandler(eventHub, eventsCfg.HeartbeatInterval, logger)
+
+	graphqlCfg := cfg.GetGraphQLConfig()
+
+	graphqlHandler, err := handler.NewGraphQLHandler(storesService, structValidator, mapper.NewStoresErrorMapper(),
+		graphqlCfg.MaxDepth, graphqlCfg.MaxComplexity, logger)
 	if err != nil {
 		logger.With(
 			zap.String("place", "main"),
 			zap.Error(err),
-		).Panic("Failed to init RabbitMQ queue")
+		).Panic("Failed to build GraphQL schema")
 	}
 
-	userRepository := repository.NewMockUserRepository()
+	adminHandler := handler.NewAdminHandler(outboxStats)
 
-	authService := service.NewAuthService(authProvider, logger, userRepository)
+	var tokenProvider middleware.JWTProvider = authProvider
 
-	errorMapper := mapper.NewAuthErrorMapper()
+	jwtCfg, err := cfg.GetJWTConfig()
+	if err != nil {
+		logger.With(
+			zap.String("place", "main"),
+			zap.Error(err),
+		).Panic("failed to read JWT config")
+	}
 
-	authHandler := handler.NewAuthHandler(authService, logger, errorMapper)
+	if jwtCfg.Mode == jwtauth.LocalMode {
+		tokenProvider, err = jwtauth.NewVerifier(*jwtCfg, logger)
+		if err != nil {
+			logger.With(
+				zap.String("place", "main"),
+				zap.Error(err),
+			).Panic("failed to set up JWT verification")
+		}
+	}
 
-	storesHandler := handler.NewStoresHandler(channel, rabbitConnection, queueName, logger, structValidator)
+	if jwtCfg.Cache.Enabled {
+		tokenProvider = jwtauth.NewCachingProvider(tokenProvider, jwtCfg.Cache.MaxTTL, jwtCfg.Cache.NegativeTTL, jwtCfg.Cache.MaxEntries)
+	}
 
-	authMiddleware := middleware.NewMiddleware(authProvider)
+	authMiddleware := middleware.NewMiddleware(tokenProvider, denylist, cfg.GetAdminConfig().Logins)
 
-	router := handler.NewRouter(authHandler, storesHandler, authMiddleware)
+	router := handler.NewRouter(authHandler, storesHandler, operationsHandler, eventsHandler, graphqlHandler, adminHandler, authMiddleware, idempotencyStore, brokerState, spooling, operationsCfg.CallbackSecret)
 
 	srvCfg := cfg.GetHTTPSrvConfig()
 
 	srv := server.NewServer(srvCfg, router, logger)
 
 	ctx, cancel := context.WithCancel(context.Background())
+
+	go operationTracker.Run(ctx, operationsCfg.CleanupInterval)
+
+	go idempotencyStore.Run(ctx, idempotencyCfg.CleanupInterval)
+
+	go eventHub.Run(ctx, eventsCfg.CleanupInterval)
+
+	for _, run := range background {
+		go run(ctx)
+	}
+
+	if srvCfg.GRPCPort != "" {
+		grpcSrv := grpcapi.NewServer(srvCfg.Host+":"+srvCfg.GRPCPort,
+			grpcapi.NewAuthServer(authService, logger, errorMapper),
+			grpcapi.NewStoresServer(storesService, structValidator, mapper.NewStoresErrorMapper()),
+			authMiddleware, logger)
+
+		go func() {
+			if err := grpcSrv.Run(ctx); err != nil {
+				logger.With(
+					zap.String("place", "main"),
+					zap.Error(err),
+				).Error("gRPC server failed during run")
+			}
+		}()
+	}
+
 	go func() {
 		if err := srv.Run(ctx); err != nil {
 			logger.With(
@@ -133,28 +465,12 @@ func initLogger() (*zap.Logger, error) {
 	return logger, err
 }
 
-func declareRabbitQueue(channel *amqp.Channel) (string, error) {
-	queue, err := channel.QueueDeclare(
-		"CreateQueue", // name
-		false,         // durable
-		false,         // delete when unused
-		false,         // exclusive
-		false,         // no-wait
-		nil,           // arguments
-	)
-	return queue.Name, err
-}
-
-func initRabbitChannel(connection *amqp.Connection) (*amqp.Channel, error) {
-	channel, err := connection.Channel()
-
-	return channel, err
-}
-
-func initRabbitMQConnection(cfg *config.Configurator) (*amqp.Connection, error) {
-	mqConfig := cfg.GetRabbitMQConfig()
-
-	conn, err := amqp.Dial(cfg.GetAMQPConnectionURL(mqConfig))
-
-	return conn, err
+// mustCheckInterval stops the start up on an interval a background loop would panic with
+func mustCheckInterval(logger *zap.Logger, name string, interval time.Duration) {
+	if err := cleanup.CheckInterval(name, interval); err != nil {
+		logger.With(
+			zap.String("place", "main"),
+			zap.Error(err),
+		).Panic("invalid config")
+	}
 }
@@ -0,0 +1,79 @@
+package bus
+
+import (
+	"GatewayService/internal/message"
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	RabbitMQ = "rabbitmq"
+	Memory   = "memory"
+	NATS     = "nats"
+)
+
+// A reply carries its outcome in the reply-status header. A reply without
+// the header is ok, the body of an error reply is the error message
+const (
+	ReplyStatusHeader = "reply-status"
+
+	ReplyOK       = "ok"
+	ReplyNotFound = "not-found"
+	ReplyError    = "error"
+)
+
+var (
+	ErrNoReply       = errors.New("no reply received in time")
+	ErrNoSubscribers = errors.New("nobody is subscribed to the action")
+	ErrClosed        = errors.New("message bus is closed")
+	ErrUnavailable   = errors.New("message bus is unavailable")
+	// ErrNotFound is the reply to a read of an item that does not exist
+	ErrNotFound = errors.New("requested item does not exist")
+	// ErrStorage is the reply of a subscriber that failed to process a request
+	ErrStorage = errors.New("storage service failed to process the request")
+)
+
+// Handler processes a delivered envelope. The returned body is sent back
+// as the reply when the envelope was published with Request, an error as
+// a reply with the status ReplyStatus gives for it
+type Handler func(ctx context.Context, envelope message.Envelope) ([]byte, error)
+
+// ReplyStatus is the reply-status of a reply to a handler that returned err
+func ReplyStatus(err error) string {
+	switch {
+	case err == nil:
+		return ReplyOK
+	case errors.Is(err, ErrNotFound):
+		return ReplyNotFound
+	default:
+		return ReplyError
+	}
+}
+
+// ReplyErr turns the reply-status and body of a reply into the error the
+// reply stands for, nil when the reply is ok
+func ReplyErr(status string, body []byte) error {
+	switch status {
+	case "", ReplyOK:
+		return nil
+	case ReplyNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("%w: %s", ErrStorage, body)
+	}
+}
+
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// MessageBus carries store actions between the gateway and the storage service
+type MessageBus interface {
+	// Publish returns once the bus has taken responsibility for the envelope
+	Publish(ctx context.Context, envelope message.Envelope) error
+	// Request publishes the envelope and waits for its reply until ctx is done
+	Request(ctx context.Context, envelope message.Envelope) ([]byte, error)
+	Subscribe(action message.Action, handler Handler) (Subscription, error)
+	Close() error
+}
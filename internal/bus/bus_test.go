@@ -0,0 +1,37 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestReplyStatusRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status string
+		want   error
+	}{
+		{"ok", nil, ReplyOK, nil},
+		{"not found", fmt.Errorf("store s1: %w", ErrNotFound), ReplyNotFound, ErrNotFound},
+		{"failure", errors.New("disk full"), ReplyError, ErrStorage},
+	}
+
+	for _, tt := range tests {
+		status := ReplyStatus(tt.err)
+		if status != tt.status {
+			t.Errorf("%s: status %q, want %q", tt.name, status, tt.status)
+		}
+
+		err := ReplyErr(status, []byte("disk full"))
+		if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("%s: reply error %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// replies of a storage service not sending the header are ok
+	if err := ReplyErr("", []byte("{}")); err != nil {
+		t.Errorf("reply without status: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/message"
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Bus delivers envelopes to in-process subscribers. It is meant for tests and
+// local development without a broker
+type Bus struct {
+	logger *zap.Logger
+
+	mu          sync.RWMutex
+	subscribers map[message.Action][]*subscription
+	next        map[message.Action]int
+	closed      bool
+}
+
+type subscription struct {
+	bus     *Bus
+	action  message.Action
+	handler bus.Handler
+}
+
+func NewBus(logger *zap.Logger) *Bus {
+	return &Bus{
+		logger:      logger,
+		subscribers: make(map[message.Action][]*subscription),
+		next:        make(map[message.Action]int),
+	}
+}
+
+// Publish hands the envelope to one subscriber of its action, like a queue
+// shared by competing consumers, without waiting for it to be processed
+func (b *Bus) Publish(_ context.Context, envelope message.Envelope) error {
+	sub, err := b.pick(envelope.Action)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if _, err := sub.handler(context.Background(), envelope); err != nil {
+			b.logger.With(
+				zap.String("place", "MemoryBus"),
+				zap.String("action", string(envelope.Action)),
+				zap.Error(err),
+			).Warn("Subscriber failed to process message")
+		}
+	}()
+
+	return nil
+}
+
+func (b *Bus) Request(ctx context.Context, envelope message.Envelope) ([]byte, error) {
+	sub, err := b.pick(envelope.Action)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		body []byte
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		body, err := sub.handler(ctx, envelope)
+		done <- result{body: body, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.body, res.err
+	case <-ctx.Done():
+		return nil, bus.ErrNoReply
+	}
+}
+
+func (b *Bus) Subscribe(action message.Action, handler bus.Handler) (bus.Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, bus.ErrClosed
+	}
+
+	sub := &subscription{bus: b, action: action, handler: handler}
+	b.subscribers[action] = append(b.subscribers[action], sub)
+
+	return sub, nil
+}
+
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.subscribers = make(map[message.Action][]*subscription)
+
+	return nil
+}
+
+// Connected and RetryAfter let the bus stand in for a broker connection
+func (b *Bus) Connected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return !b.closed
+}
+
+func (b *Bus) RetryAfter() time.Duration {
+	return time.Second
+}
+
+// pick chooses the subscribers of an action round-robin
+func (b *Bus) pick(action message.Action) (*subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, bus.ErrClosed
+	}
+
+	subs := b.subscribers[action]
+	if len(subs) == 0 {
+		return nil, bus.ErrNoSubscribers
+	}
+
+	i := b.next[action] % len(subs)
+	b.next[action] = i + 1
+
+	return subs[i], nil
+}
+
+func (s *subscription) Unsubscribe() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	subs := s.bus.subscribers[s.action]
+	for i, sub := range subs {
+		if sub == s {
+			s.bus.subscribers[s.action] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package memory
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/message"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	errStoreNotFound   = errors.New("store not found")
+	errVersionNotFound = errors.New("store version not found")
+)
+
+// versionFields are the store fields every version keeps a copy of
+var versionFields = []string{"ownerName", "openingTime", "closingTime"}
+
+// CompleteFunc reports the result of a mutating action, as the storage
+// service does by posting to /response/
+type CompleteFunc func(operationId string, data json.RawMessage, errMessage string)
+
+// Storage stands in for the storage service on the in-memory bus. It keeps
+// the stores in memory and answers every action the gateway sends, so the
+// store endpoints work without a storage service. Reads of unknown items
+// fail with bus.ErrNotFound
+type Storage struct {
+	complete CompleteFunc
+	logger   *zap.Logger
+
+	mu     sync.Mutex
+	stores map[string]*storedStore
+}
+
+type storedStore struct {
+	fields   map[string]interface{}
+	versions []*storedVersion
+}
+
+type storedVersion struct {
+	id      string
+	fields  map[string]interface{}
+	deleted bool
+}
+
+func NewStorage(complete CompleteFunc, logger *zap.Logger) *Storage {
+	return &Storage{
+		complete: complete,
+		logger:   logger,
+		stores:   make(map[string]*storedStore),
+	}
+}
+
+// Register subscribes the storage to every store action of b
+func (s *Storage) Register(b *Bus) error {
+	reads := map[message.Action]func(message.Envelope) (interface{}, error){
+		message.GetStore:        s.getStore,
+		message.GetStoreHistory: s.getStoreHistory,
+		message.GetStoreVersion: s.getStoreVersion,
+		message.ListStores:      s.listStores,
+	}
+
+	mutations := map[message.Action]func(message.Envelope) (interface{}, error){
+		message.CreateStore:         s.createStore,
+		message.CreateStoreVersion:  s.createStoreVersion,
+		message.UpdateStore:         s.updateStore,
+		message.RestoreStoreVersion: s.createStoreVersion,
+		message.DeleteStore:         s.deleteStore,
+		message.DeleteStoreVersion:  s.deleteStoreVersion,
+	}
+
+	for action, read := range reads {
+		if _, err := b.Subscribe(action, s.reply(read)); err != nil {
+			return err
+		}
+	}
+
+	for action, mutate := range mutations {
+		if _, err := b.Subscribe(action, s.apply(mutate)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reply answers a read with its JSON result
+func (s *Storage) reply(read func(message.Envelope) (interface{}, error)) func(context.Context, message.Envelope) ([]byte, error) {
+	return func(_ context.Context, envelope message.Envelope) ([]byte, error) {
+		result, err := read(envelope)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(result)
+	}
+}
+
+// apply performs a mutation and reports its result for the operation
+func (s *Storage) apply(mutate func(message.Envelope) (interface{}, error)) func(context.Context, message.Envelope) ([]byte, error) {
+	return func(_ context.Context, envelope message.Envelope) ([]byte, error) {
+		result, err := mutate(envelope)
+		if err != nil {
+			s.complete(envelope.OperationID, nil, err.Error())
+			return nil, nil
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			s.complete(envelope.OperationID, nil, err.Error())
+			return nil, nil
+		}
+
+		s.logger.With(
+			zap.String("place", "MemoryStorage"),
+			zap.String("action", string(envelope.Action)),
+			zap.String("operationId", envelope.OperationID),
+		).Debug("Store action applied")
+
+		s.complete(envelope.OperationID, data, "")
+
+		return nil, nil
+	}
+}
+
+func (s *Storage) createStore(envelope message.Envelope) (interface{}, error) {
+	fields, err := payloadFields(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := uuid.NewString()
+	fields["id"] = id
+
+	store := &storedStore{fields: fields}
+	store.addVersion(fields)
+	s.stores[id] = store
+
+	return copyFields(fields), nil
+}
+
+// createStoreVersion also serves restore_store_version, whose payload is the
+// copy of the restored version
+func (s *Storage) createStoreVersion(envelope message.Envelope) (interface{}, error) {
+	fields, err := payloadFields(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, ok := s.stores[envelope.StoreID]
+	if !ok {
+		return nil, errStoreNotFound
+	}
+
+	version := store.addVersion(fields)
+	for _, name := range versionFields {
+		store.fields[name] = version.fields[name]
+	}
+
+	return version.render(), nil
+}
+
+func (s *Storage) updateStore(envelope message.Envelope) (interface{}, error) {
+	changed, err := payloadFields(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, ok := s.stores[envelope.StoreID]
+	if !ok {
+		return nil, errStoreNotFound
+	}
+
+	for name, value := range changed {
+		if name != "id" {
+			store.fields[name] = value
+		}
+	}
+
+	return copyFields(store.fields), nil
+}
+
+func (s *Storage) deleteStore(envelope message.Envelope) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.stores[envelope.StoreID]; !ok {
+		return nil, errStoreNotFound
+	}
+	delete(s.stores, envelope.StoreID)
+
+	return map[string]interface{}{"id": envelope.StoreID}, nil
+}
+
+func (s *Storage) deleteStoreVersion(envelope message.Envelope) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version := s.version(envelope.StoreID, envelope.VersionID)
+	if version == nil {
+		return nil, errVersionNotFound
+	}
+	version.deleted = true
+
+	return version.render(), nil
+}
+
+func (s *Storage) getStore(envelope message.Envelope) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, ok := s.stores[envelope.StoreID]
+	if !ok {
+		return nil, bus.ErrNotFound
+	}
+
+	return copyFields(store.fields), nil
+}
+
+func (s *Storage) getStoreHistory(envelope message.Envelope) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, ok := s.stores[envelope.StoreID]
+	if !ok {
+		return nil, bus.ErrNotFound
+	}
+
+	history := make([]map[string]interface{}, 0, len(store.versions))
+	for _, version := range store.versions {
+		history = append(history, version.render())
+	}
+
+	return history, nil
+}
+
+func (s *Storage) getStoreVersion(envelope message.Envelope) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version := s.version(envelope.StoreID, envelope.VersionID)
+	if version == nil {
+		return nil, bus.ErrNotFound
+	}
+
+	return version.render(), nil
+}
+
+// storeListQuery holds the list_stores filters the stub understands, the
+// others are ignored
+type storeListQuery struct {
+	OwnerName  string `json:"ownerName"`
+	NamePrefix string `json:"namePrefix"`
+	Limit      int    `json:"limit"`
+	Cursor     string `json:"cursor"`
+}
+
+// listStores returns the matching stores ordered by name. The cursor is the
+// offset of the next page
+func (s *Storage) listStores(envelope message.Envelope) (interface{}, error) {
+	var query storeListQuery
+	if err := json.Unmarshal(envelope.Payload, &query); err != nil {
+		return nil, err
+	}
+
+	offset, _ := strconv.Atoi(query.Cursor)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := []map[string]interface{}{}
+	for _, store := range s.stores {
+		name, _ := store.fields["name"].(string)
+		if query.OwnerName != "" && store.fields["ownerName"] != query.OwnerName {
+			continue
+		}
+		if !strings.HasPrefix(name, query.NamePrefix) {
+			continue
+		}
+		matches = append(matches, copyFields(store.fields))
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		first, _ := matches[i]["name"].(string)
+		second, _ := matches[j]["name"].(string)
+		return first < second
+	})
+
+	reply := struct {
+		Stores     []map[string]interface{} `json:"stores"`
+		NextCursor string                   `json:"nextCursor,omitempty"`
+	}{Stores: []map[string]interface{}{}}
+
+	if offset < len(matches) {
+		end := len(matches)
+		if query.Limit > 0 && offset+query.Limit < end {
+			end = offset + query.Limit
+			reply.NextCursor = strconv.Itoa(end)
+		}
+		reply.Stores = matches[offset:end]
+	}
+
+	return reply, nil
+}
+
+func (s *Storage) version(storeId, versionId string) *storedVersion {
+	store, ok := s.stores[storeId]
+	if !ok {
+		return nil
+	}
+
+	for _, version := range store.versions {
+		if version.id == versionId {
+			return version
+		}
+	}
+
+	return nil
+}
+
+func (st *storedStore) addVersion(fields map[string]interface{}) *storedVersion {
+	version := &storedVersion{
+		id:     uuid.NewString(),
+		fields: make(map[string]interface{}, len(versionFields)),
+	}
+	for _, name := range versionFields {
+		version.fields[name] = fields[name]
+	}
+
+	st.versions = append(st.versions, version)
+
+	return version
+}
+
+func (v *storedVersion) render() map[string]interface{} {
+	rendered := copyFields(v.fields)
+	rendered["id"] = v.id
+	rendered["deleted"] = v.deleted
+
+	return rendered
+}
+
+// copyFields lets results be marshalled after the lock is released
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		copied[name] = value
+	}
+
+	return copied
+}
+
+func payloadFields(envelope message.Envelope) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(envelope.Payload, &fields); err != nil {
+		return nil, err
+	}
+
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,185 @@
+package nats
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/config"
+	"GatewayService/internal/message"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+	"time"
+)
+
+// queueGroup makes the gateways subscribed to an action share its messages
+const queueGroup = "gateway"
+
+// Bus carries envelopes over NATS core. Every action has its own subject,
+// request-reply uses the NATS inbox of the connection
+type Bus struct {
+	conn          *nats.Conn
+	prefix        string
+	flushTimeout  time.Duration
+	reconnectWait time.Duration
+	logger        *zap.Logger
+}
+
+func NewBus(cfg config.NATSConfig, logger *zap.Logger) (*Bus, error) {
+	conn, err := nats.Connect(cfg.URL,
+		nats.Name("gateway-service"),
+		nats.Timeout(cfg.ConnectTimeout),
+		nats.ReconnectWait(cfg.ReconnectWait),
+		nats.MaxReconnects(cfg.MaxReconnects),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logger.With(
+				zap.String("place", "NATSBus"),
+				zap.Error(err),
+			).Warn("NATS connection lost, reconnecting")
+		}),
+		nats.ReconnectHandler(func(_ *nats.Conn) {
+			logger.With(
+				zap.String("place", "NATSBus"),
+			).Info("NATS connection restored")
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bus{
+		conn:          conn,
+		prefix:        cfg.SubjectPrefix,
+		flushTimeout:  cfg.FlushTimeout,
+		reconnectWait: cfg.ReconnectWait,
+		logger:        logger,
+	}, nil
+}
+
+// Publish waits for the server to take the message. NATS core does not
+// persist it, so it is lost if nobody is subscribed
+func (b *Bus) Publish(_ context.Context, envelope message.Envelope) error {
+	msg, err := b.message(envelope)
+	if err != nil {
+		return err
+	}
+
+	if err := b.conn.PublishMsg(msg); err != nil {
+		return mapError(err)
+	}
+
+	if err := b.conn.FlushTimeout(b.flushTimeout); err != nil {
+		// a flush timing out means the server is unreachable, not that a reply is late
+		return fmt.Errorf("%w: %v", bus.ErrUnavailable, err)
+	}
+
+	return nil
+}
+
+func (b *Bus) Request(ctx context.Context, envelope message.Envelope) ([]byte, error) {
+	msg, err := b.message(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := b.conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	if err := bus.ReplyErr(reply.Header.Get(bus.ReplyStatusHeader), reply.Data); err != nil {
+		return nil, err
+	}
+
+	return reply.Data, nil
+}
+
+func (b *Bus) Subscribe(action message.Action, handler bus.Handler) (bus.Subscription, error) {
+	subscription, err := b.conn.QueueSubscribe(b.subject(action), queueGroup, func(msg *nats.Msg) {
+		logger := b.logger.With(
+			zap.String("place", "NATSBus"),
+			zap.String("subject", msg.Subject),
+		)
+
+		var envelope message.Envelope
+		if err := json.Unmarshal(msg.Data, &envelope); err != nil {
+			logger.With(zap.Error(err)).Warn("Dropping undecodable message")
+			return
+		}
+
+		body, err := handler(context.Background(), envelope)
+		if err != nil && !errors.Is(err, bus.ErrNotFound) {
+			logger.With(zap.Error(err)).Warn("Subscriber failed to process message")
+			body = []byte(err.Error())
+		}
+
+		if msg.Reply == "" {
+			return
+		}
+
+		reply := nats.NewMsg(msg.Reply)
+		reply.Header.Set(bus.ReplyStatusHeader, bus.ReplyStatus(err))
+		reply.Data = body
+
+		if err := msg.RespondMsg(reply); err != nil {
+			logger.With(zap.Error(err)).Error("Failed to send reply")
+		}
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return subscription, nil
+}
+
+func (b *Bus) Close() error {
+	return b.conn.Drain()
+}
+
+func (b *Bus) Connected() bool {
+	return b.conn.IsConnected()
+}
+
+func (b *Bus) RetryAfter() time.Duration {
+	return b.reconnectWait
+}
+
+func (b *Bus) subject(action message.Action) string {
+	return b.prefix + "." + string(action)
+}
+
+func (b *Bus) message(envelope message.Envelope) (*nats.Msg, error) {
+	body, err := envelope.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := nats.NewMsg(b.subject(envelope.Action))
+	msg.Data = body
+	msg.Header.Set("message-id", envelope.MessageID)
+	if envelope.CorrelationID != "" {
+		msg.Header.Set("correlation-id", envelope.CorrelationID)
+	}
+	for key, value := range envelope.Headers() {
+		msg.Header.Set(key, value)
+	}
+
+	return msg, nil
+}
+
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, nats.ErrNoResponders):
+		return bus.ErrNoSubscribers
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled), errors.Is(err, nats.ErrTimeout):
+		return bus.ErrNoReply
+	case errors.Is(err, nats.ErrConnectionClosed), errors.Is(err, nats.ErrConnectionDraining),
+		errors.Is(err, nats.ErrConnectionReconnecting), errors.Is(err, nats.ErrDisconnected):
+		return fmt.Errorf("%w: %v", bus.ErrUnavailable, err)
+	default:
+		return err
+	}
+}
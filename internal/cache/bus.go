@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/message"
+	"context"
+	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+// cached are the reads answered from the cache
+var cached = map[message.Action]bool{
+	message.GetStore:        true,
+	message.GetStoreHistory: true,
+	message.GetStoreVersion: true,
+}
+
+// invalidating are the mutations after which the cached replies about their
+// store are stale
+var invalidating = map[message.Action]bool{
+	message.CreateStoreVersion:  true,
+	message.DeleteStore:         true,
+	message.DeleteStoreVersion:  true,
+	message.UpdateStore:         true,
+	message.RestoreStoreVersion: true,
+}
+
+// Bus is a read-through cache in front of another MessageBus
+type Bus struct {
+	bus.MessageBus
+	cache  Cache
+	logger *zap.Logger
+
+	// bumped on every invalidation, a reply fetched meanwhile may be stale
+	// and is not cached
+	invalidations atomic.Uint64
+}
+
+func NewBus(next bus.MessageBus, cache Cache, logger *zap.Logger) *Bus {
+	return &Bus{
+		MessageBus: next,
+		cache:      cache,
+		logger:     logger,
+	}
+}
+
+// Publish invalidates the store before publishing, so a read racing with
+// the mutation does not see the store as it was cached
+func (b *Bus) Publish(ctx context.Context, envelope message.Envelope) error {
+	if invalidating[envelope.Action] && envelope.StoreID != "" {
+		b.InvalidateStore(ctx, envelope.StoreID)
+	}
+
+	return b.MessageBus.Publish(ctx, envelope)
+}
+
+// InvalidateStore drops the cached replies about a store. The mutation is
+// applied asynchronously, so it is called again once the storage service
+// reports it done: a read between publish and apply may have cached the
+// store as it was before
+func (b *Bus) InvalidateStore(ctx context.Context, storeId string) {
+	b.invalidations.Add(1)
+	if err := b.cache.Invalidate(ctx, storeId); err != nil {
+		b.logger.With(
+			zap.String("place", "CacheBus"),
+			zap.String("storeId", storeId),
+			zap.Error(err),
+		).Error("Failed to invalidate cached store")
+	}
+}
+
+func (b *Bus) Request(ctx context.Context, envelope message.Envelope) ([]byte, error) {
+	if !cached[envelope.Action] || envelope.StoreID == "" {
+		return b.MessageBus.Request(ctx, envelope)
+	}
+
+	logger := b.logger.With(
+		zap.String("place", "CacheBus"),
+		zap.String("storeId", envelope.StoreID),
+	)
+
+	key := cacheKey(envelope)
+
+	value, ok, err := b.cache.Get(ctx, key)
+	if err != nil {
+		logger.With(zap.Error(err)).Warn("Failed to read cache, asking the storage service")
+	}
+	if ok {
+		cacheMetrics.Add("hits", 1)
+		return value, nil
+	}
+	cacheMetrics.Add("misses", 1)
+
+	seen := b.invalidations.Load()
+
+	body, err := b.MessageBus.Request(ctx, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.invalidations.Load() == seen {
+		if err := b.cache.Set(ctx, envelope.StoreID, key, body); err != nil {
+			logger.With(zap.Error(err)).Warn("Failed to cache reply")
+		}
+	}
+
+	return body, nil
+}
+
+// cacheKey is per login, the storage service may answer users differently
+func cacheKey(envelope message.Envelope) string {
+	return string(envelope.Action) + ":" + envelope.StoreID + ":" + envelope.VersionID + ":" + envelope.UserLogin
+}
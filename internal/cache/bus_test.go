@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/message"
+	"context"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+// fakeBus answers every request with reply and counts them
+type fakeBus struct {
+	bus.MessageBus
+	requests int
+	reply    func() ([]byte, error)
+}
+
+func (b *fakeBus) Request(context.Context, message.Envelope) ([]byte, error) {
+	b.requests++
+	return b.reply()
+}
+
+func (b *fakeBus) Publish(context.Context, message.Envelope) error {
+	return nil
+}
+
+func newFakeCacheBus(reply func() ([]byte, error)) (*Bus, *fakeBus) {
+	next := &fakeBus{reply: reply}
+
+	return NewBus(next, NewLRU(10, time.Minute), zap.NewNop()), next
+}
+
+var getStore = message.Envelope{Action: message.GetStore, StoreID: "s1", UserLogin: "tester"}
+
+func TestBusAnswersFromCache(t *testing.T) {
+	b, next := newFakeCacheBus(func() ([]byte, error) {
+		return []byte(`{"id":"s1"}`), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		body, err := b.Request(context.Background(), getStore)
+		if err != nil || string(body) != `{"id":"s1"}` {
+			t.Fatalf("request %d: %s, %v", i, body, err)
+		}
+	}
+
+	if next.requests != 1 {
+		t.Errorf("storage service was asked %d times, want once", next.requests)
+	}
+}
+
+func TestBusCachesOnlySuccessfulReplies(t *testing.T) {
+	for _, replyErr := range []error{bus.ErrNotFound, bus.ErrStorage} {
+		b, next := newFakeCacheBus(func() ([]byte, error) {
+			return nil, replyErr
+		})
+
+		for i := 0; i < 2; i++ {
+			if _, err := b.Request(context.Background(), getStore); err != replyErr {
+				t.Fatalf("got %v, want %v", err, replyErr)
+			}
+		}
+
+		if next.requests != 2 {
+			t.Errorf("%v: storage service was asked %d times, want twice", replyErr, next.requests)
+		}
+	}
+}
+
+func TestBusInvalidatesOnMutation(t *testing.T) {
+	b, next := newFakeCacheBus(func() ([]byte, error) {
+		return []byte(`{"id":"s1"}`), nil
+	})
+
+	if _, err := b.Request(context.Background(), getStore); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish(context.Background(), message.Envelope{Action: message.UpdateStore, StoreID: "s1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Request(context.Background(), getStore); err != nil {
+		t.Fatal(err)
+	}
+
+	if next.requests != 2 {
+		t.Errorf("storage service was asked %d times, want twice", next.requests)
+	}
+}
+
+func TestBusDoesNotCacheReplyRacingInvalidation(t *testing.T) {
+	var b *Bus
+	b, next := newFakeCacheBus(func() ([]byte, error) {
+		// the store changes while its old state is on the way back
+		b.InvalidateStore(context.Background(), "s1")
+		return []byte(`{"id":"s1","name":"old"}`), nil
+	})
+
+	if _, err := b.Request(context.Background(), getStore); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cachedValue(t, b.cache, cacheKey(getStore)); ok {
+		t.Error("reply fetched during an invalidation was cached")
+	}
+
+	next.reply = func() ([]byte, error) {
+		return []byte(`{"id":"s1","name":"new"}`), nil
+	}
+
+	if body, _ := b.Request(context.Background(), getStore); string(body) != `{"id":"s1","name":"new"}` {
+		t.Errorf("got %s, want the new store", body)
+	}
+	if _, ok := cachedValue(t, b.cache, cacheKey(getStore)); !ok {
+		t.Error("reply after the invalidation was not cached")
+	}
+}
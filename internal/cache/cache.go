@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"expvar"
+)
+
+const (
+	MemoryBackend = "memory"
+	RedisBackend  = "redis"
+)
+
+var cacheMetrics = expvar.NewMap("store_cache")
+
+// Cache keeps storage service replies for the TTL it was created with.
+// Every entry belongs to a store, so all the replies about a store can be
+// dropped at once when the store changes
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, storeId, key string, value []byte) error
+	Invalidate(ctx context.Context, storeId string) error
+}
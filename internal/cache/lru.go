@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	storeId   string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU is an in-memory Cache holding at most maxEntries entries, the least
+// recently used one is evicted first
+type LRU struct {
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	stores  map[string]map[string]struct{}
+}
+
+func NewLRU(maxEntries int, ttl time.Duration) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		stores:     make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := element.Value.(*lruEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.remove(element)
+		return nil, false, nil
+	}
+
+	c.order.MoveToFront(element)
+
+	return entry.value, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, storeId, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	element := c.order.PushFront(&lruEntry{
+		key:       key,
+		storeId:   storeId,
+		value:     value,
+		expiresAt: time.Now().Add(c.ttl),
+	})
+	c.entries[key] = element
+
+	keys, ok := c.stores[storeId]
+	if !ok {
+		keys = make(map[string]struct{})
+		c.stores[storeId] = keys
+	}
+	keys[key] = struct{}{}
+
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+
+	return nil
+}
+
+func (c *LRU) Invalidate(_ context.Context, storeId string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.stores[storeId] {
+		c.remove(c.entries[key])
+	}
+
+	return nil
+}
+
+func (c *LRU) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+
+	keys := c.stores[entry.storeId]
+	delete(keys, entry.key)
+	if len(keys) == 0 {
+		delete(c.stores, entry.storeId)
+	}
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func cachedValue(t *testing.T, c Cache, key string) (string, bool) {
+	t.Helper()
+
+	value, ok, err := c.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(value), ok
+}
+
+func set(t *testing.T, c Cache, storeId, key, value string) {
+	t.Helper()
+
+	if err := c.Set(context.Background(), storeId, key, []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2, time.Minute)
+
+	set(t, c, "s1", "a", "1")
+	set(t, c, "s1", "b", "2")
+
+	// reading a makes b the least recently used entry
+	if _, ok := cachedValue(t, c, "a"); !ok {
+		t.Fatal("a is not cached")
+	}
+
+	set(t, c, "s2", "c", "3")
+
+	if _, ok := cachedValue(t, c, "b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cachedValue(t, c, key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestLRUReplacesEntry(t *testing.T) {
+	c := NewLRU(2, time.Minute)
+
+	set(t, c, "s1", "a", "1")
+	set(t, c, "s1", "a", "2")
+	set(t, c, "s1", "b", "3")
+
+	if value, ok := cachedValue(t, c, "a"); !ok || value != "2" {
+		t.Errorf("a is %q, %v, want the replaced value", value, ok)
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	c := NewLRU(2, 20*time.Millisecond)
+
+	set(t, c, "s1", "a", "1")
+	if _, ok := cachedValue(t, c, "a"); !ok {
+		t.Fatal("a is not cached")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := cachedValue(t, c, "a"); ok {
+		t.Error("a outlived its TTL")
+	}
+	if c.order.Len() != 0 || len(c.stores) != 0 {
+		t.Error("expired entry was kept")
+	}
+}
+
+func TestLRUInvalidatesStore(t *testing.T) {
+	c := NewLRU(10, time.Minute)
+
+	set(t, c, "s1", "a", "1")
+	set(t, c, "s1", "b", "2")
+	set(t, c, "s2", "c", "3")
+
+	if err := c.Invalidate(context.Background(), "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if _, ok := cachedValue(t, c, key); ok {
+			t.Errorf("%s survived the invalidation of its store", key)
+		}
+	}
+	if _, ok := cachedValue(t, c, "c"); !ok {
+		t.Error("c of another store was invalidated")
+	}
+}
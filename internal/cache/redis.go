@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Redis is a Cache shared by every gateway instance, on any server speaking
+// the Redis protocol. The keys of a store are tracked in a set so they can be
+// deleted together
+type Redis struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedis(client *redis.Client, prefix string, ttl time.Duration) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, storeId, key string, value []byte) error {
+	storeKey := c.storeKey(storeId)
+
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, c.prefix+key, value, c.ttl)
+		pipe.SAdd(ctx, storeKey, c.prefix+key)
+		pipe.Expire(ctx, storeKey, c.ttl)
+		return nil
+	})
+
+	return err
+}
+
+func (c *Redis) Invalidate(ctx context.Context, storeId string) error {
+	storeKey := c.storeKey(storeId)
+
+	keys, err := c.client.SMembers(ctx, storeKey).Result()
+	if err != nil {
+		return err
+	}
+
+	return c.client.Del(ctx, append(keys, storeKey)...).Err()
+}
+
+func (c *Redis) Close() error {
+	return c.client.Close()
+}
+
+func (c *Redis) storeKey(storeId string) string {
+	return c.prefix + "store-keys:" + storeId
+}
@@ -0,0 +1,34 @@
+// Package cleanup runs the periodic eviction of the in-memory stores
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CheckInterval rejects the intervals a ticker cannot run with, e.g. the zero
+// value of a key missing from the config
+func CheckInterval(name string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("%s must be positive, got %s", name, interval)
+	}
+
+	return nil
+}
+
+// Run calls evict with the current time every interval until ctx is done.
+// The interval must have passed CheckInterval
+func Run(ctx context.Context, interval time.Duration, evict func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			evict(now)
+		}
+	}
+}
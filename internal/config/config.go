@@ -10,10 +10,63 @@ import (
 )
 
 type RabbitMQConfig struct {
-	Host     string
-	Port     string
-	Username string
-	Password string
+	Host           string
+	Port           string
+	Username       string
+	Password       string
+	ConfirmTimeout time.Duration
+	// backoff between reconnection attempts, doubled up to ReconnectMaxBackoff
+	ReconnectBackoff    time.Duration
+	ReconnectMaxBackoff time.Duration
+	// number of pooled publisher channels and how long a publish may wait for one
+	PublisherChannels    int
+	PublisherWaitTimeout time.Duration
+	// queue the store actions are published to, declared in Topology
+	Queue    string
+	Topology TopologyConfig
+	Routing  RoutingConfig
+}
+
+type RoutingConfig struct {
+	// queue publishes everything to Queue through the default exchange,
+	// topic publishes to Exchange with a routing key per action
+	Mode     string `mapstructure:"mode"`
+	Exchange string `mapstructure:"exchange"`
+	// appends ".<shard>" derived from the store ID to the routing key when positive
+	Shards int               `mapstructure:"shards"`
+	Keys   map[string]string `mapstructure:"keys"`
+}
+
+type TopologyConfig struct {
+	Exchanges []ExchangeConfig `mapstructure:"exchanges"`
+	Queues    []QueueConfig    `mapstructure:"queues"`
+	Bindings  []BindingConfig  `mapstructure:"bindings"`
+}
+
+type ExchangeConfig struct {
+	Name       string `mapstructure:"name"`
+	Kind       string `mapstructure:"kind"`
+	Durable    bool   `mapstructure:"durable"`
+	AutoDelete bool   `mapstructure:"autoDelete"`
+}
+
+type QueueConfig struct {
+	Name string `mapstructure:"name"`
+	// classic or quorum, classic when empty
+	Type                 string        `mapstructure:"type"`
+	Durable              bool          `mapstructure:"durable"`
+	DeadLetterExchange   string        `mapstructure:"deadLetterExchange"`
+	DeadLetterRoutingKey string        `mapstructure:"deadLetterRoutingKey"`
+	MessageTTL           time.Duration `mapstructure:"messageTtl"`
+	MaxLength            int64         `mapstructure:"maxLength"`
+	// drop-head, reject-publish or reject-publish-dlx, broker default when empty
+	Overflow string `mapstructure:"overflow"`
+}
+
+type BindingConfig struct {
+	Queue      string `mapstructure:"queue"`
+	Exchange   string `mapstructure:"exchange"`
+	RoutingKey string `mapstructure:"routingKey"`
 }
 
 type HTTPServerConfig struct {
@@ -23,6 +76,8 @@ type HTTPServerConfig struct {
 	TimeOutSec        int
 	Port              string
 	Host              string
+	// gRPC API port, the gRPC server is not started when empty
+	GRPCPort string
 }
 
 type Configurator struct {
@@ -53,16 +108,35 @@ func (cfg *Configurator) GetHTTPSrvConfig() *HTTPServerConfig {
 		TimeOutSec:        viper.GetInt("srv.timeOutSec"),
 		Port:              viper.GetString("srv.port"),
 		Host:              viper.GetString("srv.host"),
+		GRPCPort:          viper.GetString("srv.grpcPort"),
 	}
 }
 
-func (cfg *Configurator) GetRabbitMQConfig() *RabbitMQConfig {
-	return &RabbitMQConfig{
-		Password: viper.GetString("rabbit.password"),
-		Username: viper.GetString("rabbit.username"),
-		Port:     viper.GetString("rabbit.port"),
-		Host:     viper.GetString("rabbit.host"),
+func (cfg *Configurator) GetRabbitMQConfig() (*RabbitMQConfig, error) {
+	var topology TopologyConfig
+	if err := viper.UnmarshalKey("rabbit.topology", &topology); err != nil {
+		return nil, fmt.Errorf("failed to read rabbit topology: %w", err)
 	}
+
+	var routing RoutingConfig
+	if err := viper.UnmarshalKey("rabbit.routing", &routing); err != nil {
+		return nil, fmt.Errorf("failed to read rabbit routing: %w", err)
+	}
+
+	return &RabbitMQConfig{
+		Password:             viper.GetString("rabbit.password"),
+		Username:             viper.GetString("rabbit.username"),
+		Port:                 viper.GetString("rabbit.port"),
+		Host:                 viper.GetString("rabbit.host"),
+		ConfirmTimeout:       viper.GetDuration("rabbit.confirmTimeout"),
+		ReconnectBackoff:     viper.GetDuration("rabbit.reconnectBackoff"),
+		ReconnectMaxBackoff:  viper.GetDuration("rabbit.reconnectMaxBackoff"),
+		PublisherChannels:    viper.GetInt("rabbit.publisherChannels"),
+		PublisherWaitTimeout: viper.GetDuration("rabbit.publisherWaitTimeout"),
+		Queue:                viper.GetString("rabbit.queue"),
+		Topology:             topology,
+		Routing:              routing,
+	}, nil
 }
 
 func (cfg *Configurator) GetAMQPConnectionURL(rabbitCfg *RabbitMQConfig) string {
@@ -114,3 +188,285 @@ func (cfg *Configurator) GetAuthProviderConfig(logger *zap.Logger) *AuthProvider
 	}
 	return provider
 }
+
+type JWTConfig struct {
+	// local verifies tokens in the gateway, remote calls the auth generator /validate
+	Mode string
+	// accepted signing methods, e.g. HS256, RS256 or ES256
+	Algorithms []string
+	// shared secret of the HMAC methods
+	Secret string
+	// PEM public key files with their key ID
+	PublicKeys []PublicKeyConfig
+	// JSON Web Key Sets with public keys, the URL one is fetched again on an
+	// unknown key ID at most once per JWKSRefresh
+	JWKSFile    string
+	JWKSURL     string
+	JWKSRefresh time.Duration
+	JWKSTimeout time.Duration
+	// iss and aud tokens must carry, not checked when empty
+	Issuer   string
+	Audience string
+	Cache    TokenCacheConfig
+}
+
+type TokenCacheConfig struct {
+	Enabled bool
+	// a valid token is remembered until it expires but at most MaxTTL,
+	// a rejected one for NegativeTTL
+	MaxTTL      time.Duration
+	NegativeTTL time.Duration
+	MaxEntries  int
+}
+
+// PublicKeyConfig is read as a list, viper lowercases map keys and key IDs
+// are case sensitive
+type PublicKeyConfig struct {
+	Kid  string `mapstructure:"kid"`
+	Path string `mapstructure:"path"`
+}
+
+func (cfg *Configurator) GetJWTConfig() (*JWTConfig, error) {
+	var publicKeys []PublicKeyConfig
+	if err := viper.UnmarshalKey("jwt.publicKeys", &publicKeys); err != nil {
+		return nil, fmt.Errorf("failed to read jwt public keys: %w", err)
+	}
+
+	return &JWTConfig{
+		Mode:        viper.GetString("jwt.mode"),
+		Algorithms:  viper.GetStringSlice("jwt.algorithms"),
+		Secret:      viper.GetString("jwt.secret"),
+		PublicKeys:  publicKeys,
+		JWKSFile:    viper.GetString("jwt.jwksFile"),
+		JWKSURL:     viper.GetString("jwt.jwksUrl"),
+		JWKSRefresh: viper.GetDuration("jwt.jwksRefresh"),
+		JWKSTimeout: viper.GetDuration("jwt.jwksTimeout"),
+		Issuer:      viper.GetString("jwt.issuer"),
+		Audience:    viper.GetString("jwt.audience"),
+		Cache: TokenCacheConfig{
+			Enabled:     viper.GetBool("jwt.cache.enabled"),
+			MaxTTL:      viper.GetDuration("jwt.cache.maxTtl"),
+			NegativeTTL: viper.GetDuration("jwt.cache.negativeTtl"),
+			MaxEntries:  viper.GetInt("jwt.cache.maxEntries"),
+		},
+	}, nil
+}
+
+type RefreshConfig struct {
+	// how long a refresh token can be exchanged for new tokens
+	TTL time.Duration
+	// memory or redis
+	Store           string
+	CleanupInterval time.Duration
+	Redis           RedisConfig
+}
+
+func (cfg *Configurator) GetRefreshConfig() *RefreshConfig {
+	return &RefreshConfig{
+		TTL:             viper.GetDuration("refresh.ttl"),
+		Store:           viper.GetString("refresh.store"),
+		CleanupInterval: viper.GetDuration("refresh.cleanupInterval"),
+		Redis: RedisConfig{
+			Addr:      viper.GetString("refresh.redis.addr"),
+			Password:  viper.GetString("refresh.redis.password"),
+			DB:        viper.GetInt("refresh.redis.db"),
+			KeyPrefix: viper.GetString("refresh.redis.keyPrefix"),
+		},
+	}
+}
+
+type RevocationConfig struct {
+	// memory or redis
+	Store string
+	// how long revocations are kept, at least as long as the refresh tokens live
+	Retention       time.Duration
+	CleanupInterval time.Duration
+	Redis           RedisConfig
+}
+
+func (cfg *Configurator) GetRevocationConfig() *RevocationConfig {
+	return &RevocationConfig{
+		Store:           viper.GetString("revocation.store"),
+		Retention:       viper.GetDuration("revocation.retention"),
+		CleanupInterval: viper.GetDuration("revocation.cleanupInterval"),
+		Redis: RedisConfig{
+			Addr:      viper.GetString("revocation.redis.addr"),
+			Password:  viper.GetString("revocation.redis.password"),
+			DB:        viper.GetInt("revocation.redis.db"),
+			KeyPrefix: viper.GetString("revocation.redis.keyPrefix"),
+		},
+	}
+}
+
+// PasswordConfig holds the argon2id params of new password hashes, hashes
+// made with other params are replaced on the next sign in
+type PasswordConfig struct {
+	// KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	// bytes
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+func (cfg *Configurator) GetPasswordConfig() *PasswordConfig {
+	return &PasswordConfig{
+		Memory:      viper.GetUint32("password.memory"),
+		Iterations:  viper.GetUint32("password.iterations"),
+		Parallelism: uint8(viper.GetUint("password.parallelism")),
+		SaltLength:  viper.GetUint32("password.saltLength"),
+		KeyLength:   viper.GetUint32("password.keyLength"),
+	}
+}
+
+type OperationsConfig struct {
+	Retention       time.Duration
+	CleanupInterval time.Duration
+	// the storage service sends it with every /response/ callback
+	CallbackSecret string
+}
+
+func (cfg *Configurator) GetOperationsConfig() *OperationsConfig {
+	return &OperationsConfig{
+		Retention:       viper.GetDuration("operations.retention"),
+		CleanupInterval: viper.GetDuration("operations.cleanupInterval"),
+		CallbackSecret:  viper.GetString("operations.callbackSecret"),
+	}
+}
+
+type EventsConfig struct {
+	HeartbeatInterval time.Duration
+	HistorySize       int
+	// how long the history of a login without subscribers is kept
+	HistoryRetention time.Duration
+	CleanupInterval  time.Duration
+	BufferSize       int
+}
+
+func (cfg *Configurator) GetEventsConfig() *EventsConfig {
+	return &EventsConfig{
+		HeartbeatInterval: viper.GetDuration("events.heartbeatInterval"),
+		HistorySize:       viper.GetInt("events.historySize"),
+		HistoryRetention:  viper.GetDuration("events.historyRetention"),
+		CleanupInterval:   viper.GetDuration("events.cleanupInterval"),
+		BufferSize:        viper.GetInt("events.bufferSize"),
+	}
+}
+
+type OutboxConfig struct {
+	Enabled       bool
+	Dir           string
+	MaxBytes      int64
+	RetryInterval time.Duration
+}
+
+func (cfg *Configurator) GetOutboxConfig() *OutboxConfig {
+	return &OutboxConfig{
+		Enabled:       viper.GetBool("outbox.enabled"),
+		Dir:           viper.GetString("outbox.dir"),
+		MaxBytes:      viper.GetInt64("outbox.maxBytes"),
+		RetryInterval: viper.GetDuration("outbox.retryInterval"),
+	}
+}
+
+type AdminConfig struct {
+	Logins []string
+}
+
+func (cfg *Configurator) GetAdminConfig() *AdminConfig {
+	return &AdminConfig{
+		Logins: viper.GetStringSlice("admin.logins"),
+	}
+}
+
+type BusConfig struct {
+	// rabbitmq, nats or memory
+	Kind string
+	// how long a read waits for the storage service reply
+	ReplyTimeout time.Duration
+}
+
+func (cfg *Configurator) GetBusConfig() *BusConfig {
+	return &BusConfig{
+		Kind:         viper.GetString("bus.kind"),
+		ReplyTimeout: viper.GetDuration("bus.replyTimeout"),
+	}
+}
+
+type NATSConfig struct {
+	URL string
+	// subjects are "<SubjectPrefix>.<action>"
+	SubjectPrefix  string
+	FlushTimeout   time.Duration
+	ReconnectWait  time.Duration
+	MaxReconnects  int
+	ConnectTimeout time.Duration
+}
+
+func (cfg *Configurator) GetNATSConfig() *NATSConfig {
+	return &NATSConfig{
+		URL:            viper.GetString("nats.url"),
+		SubjectPrefix:  viper.GetString("nats.subjectPrefix"),
+		FlushTimeout:   viper.GetDuration("nats.flushTimeout"),
+		ReconnectWait:  viper.GetDuration("nats.reconnectWait"),
+		MaxReconnects:  viper.GetInt("nats.maxReconnects"),
+		ConnectTimeout: viper.GetDuration("nats.connectTimeout"),
+	}
+}
+
+type IdempotencyConfig struct {
+	// how long a key is remembered after its request completed
+	Window          time.Duration
+	CleanupInterval time.Duration
+}
+
+func (cfg *Configurator) GetIdempotencyConfig() *IdempotencyConfig {
+	return &IdempotencyConfig{
+		Window:          viper.GetDuration("idempotency.window"),
+		CleanupInterval: viper.GetDuration("idempotency.cleanupInterval"),
+	}
+}
+
+type CacheConfig struct {
+	Enabled bool
+	// memory or redis
+	Backend    string
+	TTL        time.Duration
+	MaxEntries int
+	Redis      RedisConfig
+}
+
+type RedisConfig struct {
+	Addr      string
+	Password  string
+	DB        int
+	KeyPrefix string
+}
+
+func (cfg *Configurator) GetCacheConfig() *CacheConfig {
+	return &CacheConfig{
+		Enabled:    viper.GetBool("cache.enabled"),
+		Backend:    viper.GetString("cache.backend"),
+		TTL:        viper.GetDuration("cache.ttl"),
+		MaxEntries: viper.GetInt("cache.maxEntries"),
+		Redis: RedisConfig{
+			Addr:      viper.GetString("cache.redis.addr"),
+			Password:  viper.GetString("cache.redis.password"),
+			DB:        viper.GetInt("cache.redis.db"),
+			KeyPrefix: viper.GetString("cache.redis.keyPrefix"),
+		},
+	}
+}
+
+type GraphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+func (cfg *Configurator) GetGraphQLConfig() *GraphQLConfig {
+	return &GraphQLConfig{
+		MaxDepth:      viper.GetInt("graphql.maxDepth"),
+		MaxComplexity: viper.GetInt("graphql.maxComplexity"),
+	}
+}
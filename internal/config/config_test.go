@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetJWTConfigKeepsKeyIDCase(t *testing.T) {
+	defer viper.Reset()
+
+	viper.SetConfigType("json")
+	err := viper.ReadConfig(strings.NewReader(`{"jwt": {"publicKeys": [
+		{"kid": "Key-2024", "path": "keys/a.pem"},
+		{"kid": "key-2024", "path": "keys/b.pem"}
+	]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := (&Configurator{}).GetJWTConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []PublicKeyConfig{
+		{Kid: "Key-2024", Path: "keys/a.pem"},
+		{Kid: "key-2024", Path: "keys/b.pem"},
+	}
+	if len(cfg.PublicKeys) != len(want) {
+		t.Fatalf("public keys %+v, want %+v", cfg.PublicKeys, want)
+	}
+	for i := range want {
+		if cfg.PublicKeys[i] != want[i] {
+			t.Errorf("public key %d is %+v, want %+v", i, cfg.PublicKeys[i], want[i])
+		}
+	}
+}
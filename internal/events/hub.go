@@ -0,0 +1,162 @@
+package events
+
+import (
+	"GatewayService/internal/cleanup"
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Event struct {
+	ID   uint64
+	Type string
+	Data json.RawMessage
+}
+
+func (e Event) IDString() string {
+	return strconv.FormatUint(e.ID, 10)
+}
+
+type Subscription struct {
+	login  string
+	events chan Event
+	closed bool
+}
+
+// Events is closed when the subscriber falls too far behind or unsubscribes.
+// Clients are expected to reconnect with the last event ID they have seen
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Hub fans events out to the live subscribers of a login and keeps a short
+// per-login history so reconnecting clients can catch up. The history of a
+// login without subscribers is dropped once it was idle for historyRetention
+type Hub struct {
+	mu               sync.Mutex
+	nextID           uint64
+	historySize      int
+	historyRetention time.Duration
+	bufferSize       int
+	history          map[string][]Event
+	// last publish or unsubscribe of a login
+	lastActive  map[string]time.Time
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+func NewHub(historySize int, historyRetention time.Duration, bufferSize int) *Hub {
+	return &Hub{
+		historySize:      historySize,
+		historyRetention: historyRetention,
+		bufferSize:       bufferSize,
+		history:          make(map[string][]Event),
+		lastActive:       make(map[string]time.Time),
+		subscribers:      make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Publish(login, eventType string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	event := Event{
+		ID:   h.nextID,
+		Type: eventType,
+		Data: body,
+	}
+
+	history := append(h.history[login], event)
+	if len(history) > h.historySize {
+		history = history[len(history)-h.historySize:]
+	}
+	h.history[login] = history
+	h.lastActive[login] = time.Now()
+
+	for sub := range h.subscribers[login] {
+		select {
+		case sub.events <- event:
+		default:
+			h.closeLocked(sub)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe registers a subscriber for login and returns the events it
+// missed after lastEventID. Pass 0 to skip the replay
+func (h *Hub) Subscribe(login string, lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscription{
+		login:  login,
+		events: make(chan Event, h.bufferSize),
+	}
+
+	if h.subscribers[login] == nil {
+		h.subscribers[login] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[login][sub] = struct{}{}
+
+	var missed []Event
+	if lastEventID > 0 {
+		for _, event := range h.history[login] {
+			if event.ID > lastEventID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return sub, missed
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closeLocked(sub)
+}
+
+func (h *Hub) closeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.events)
+
+	subs := h.subscribers[sub.login]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.login)
+		// the client has historyRetention to reconnect and catch up
+		h.lastActive[sub.login] = time.Now()
+	}
+}
+
+// Run drops idle histories every interval until ctx is done
+func (h *Hub) Run(ctx context.Context, interval time.Duration) {
+	cleanup.Run(ctx, interval, h.pruneIdle)
+}
+
+func (h *Hub) pruneIdle(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for login, lastActive := range h.lastActive {
+		if len(h.subscribers[login]) > 0 || now.Sub(lastActive) < h.historyRetention {
+			continue
+		}
+
+		delete(h.history, login)
+		delete(h.lastActive, login)
+	}
+}
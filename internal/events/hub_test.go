@@ -0,0 +1,46 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHubPrunesIdleHistory(t *testing.T) {
+	h := NewHub(10, time.Minute, 4)
+
+	if err := h.Publish("idle", "operation", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, _ := h.Subscribe("live", 0)
+	defer h.Unsubscribe(sub)
+
+	if err := h.Publish("live", "operation", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	h.pruneIdle(time.Now().Add(2 * time.Minute))
+
+	if _, ok := h.history["idle"]; ok {
+		t.Error("history of a login without subscribers survived its retention")
+	}
+	if _, ok := h.history["live"]; !ok {
+		t.Error("history of a subscribed login was pruned")
+	}
+}
+
+func TestHubKeepsHistoryWithinRetention(t *testing.T) {
+	h := NewHub(10, time.Minute, 4)
+
+	sub, _ := h.Subscribe("user", 0)
+	if err := h.Publish("user", "operation", 1); err != nil {
+		t.Fatal(err)
+	}
+	h.Unsubscribe(sub)
+
+	h.pruneIdle(time.Now().Add(30 * time.Second))
+
+	if got := len(h.history["user"]); got != 1 {
+		t.Fatalf("history within retention: got %d events, want 1", got)
+	}
+}
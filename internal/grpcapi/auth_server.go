@@ -0,0 +1,82 @@
+package grpcapi
+
+import (
+	"GatewayService/internal/grpcapi/pb"
+	"GatewayService/internal/handler"
+	"GatewayService/internal/handler/mapper"
+	"GatewayService/internal/service"
+	"context"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+	"time"
+)
+
+// AuthServer validates its requests like the REST handler binds them, with
+// the binding tags of handler.Auth and handler.RefreshRequest
+type AuthServer struct {
+	pb.UnimplementedAuthServiceServer
+
+	authService handler.AuthService
+	logger      *zap.Logger
+	errorMapper mapper.ErrorMapper
+}
+
+func NewAuthServer(authService handler.AuthService, logger *zap.Logger, errorMapper mapper.ErrorMapper) *AuthServer {
+	return &AuthServer{
+		authService: authService,
+		logger:      logger,
+		errorMapper: errorMapper,
+	}
+}
+
+func (s *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	auth := handler.Auth{
+		Login:    req.GetLogin(),
+		Password: req.GetPassword(),
+	}
+
+	if err := binding.Validator.ValidateStruct(auth); err != nil {
+		return nil, validationError(err)
+	}
+
+	tokens, err := s.authService.SignIn(ctx, service.User{
+		Login:    auth.Login,
+		Password: auth.Password,
+	})
+	if err != nil {
+		s.logger.With(
+			zap.String("place", "grpcAuthServer"),
+			zap.String("func", "Login"),
+		).Error("Error while signing in: " + err.Error())
+		return nil, mappedError(s.errorMapper, err)
+	}
+
+	return toLoginResponse(tokens), nil
+}
+
+func (s *AuthServer) Refresh(ctx context.Context, req *pb.RefreshRequest) (*pb.LoginResponse, error) {
+	refresh := handler.RefreshRequest{RefreshToken: req.GetRefreshToken()}
+
+	if err := binding.Validator.ValidateStruct(refresh); err != nil {
+		return nil, validationError(err)
+	}
+
+	tokens, err := s.authService.Refresh(ctx, refresh.RefreshToken)
+	if err != nil {
+		s.logger.With(
+			zap.String("place", "grpcAuthServer"),
+			zap.String("func", "Refresh"),
+		).Error("Error while refreshing tokens: " + err.Error())
+		return nil, mappedError(s.errorMapper, err)
+	}
+
+	return toLoginResponse(tokens), nil
+}
+
+func toLoginResponse(tokens service.Tokens) *pb.LoginResponse {
+	return &pb.LoginResponse{
+		AccessToken:      tokens.AccessToken,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,45 @@
+package grpcapi
+
+import (
+	"GatewayService/internal/grpcapi/pb"
+	"GatewayService/internal/handler/mapper"
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+func TestAuthServerValidatesLikeREST(t *testing.T) {
+	// invalid requests never reach the auth service
+	s := NewAuthServer(nil, zap.NewNop(), mapper.NewAuthErrorMapper())
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"short login", func() error {
+			_, err := s.Login(context.Background(), &pb.LoginRequest{Login: "ab", Password: "secret1"})
+			return err
+		}},
+		{"long password", func() error {
+			_, err := s.Login(context.Background(), &pb.LoginRequest{Login: "alice", Password: strings.Repeat("x", 41)})
+			return err
+		}},
+		{"missing refresh token", func() error {
+			_, err := s.Refresh(context.Background(), &pb.RefreshRequest{})
+			return err
+		}},
+		{"long refresh token", func() error {
+			_, err := s.Refresh(context.Background(), &pb.RefreshRequest{RefreshToken: strings.Repeat("x", 101)})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		if code := status.Code(tt.call()); code != codes.InvalidArgument {
+			t.Errorf("%s: code %s, want InvalidArgument", tt.name, code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package grpcapi
+
+import (
+	"GatewayService/internal/handler/mapper"
+	"GatewayService/internal/handler/validation"
+	"encoding/json"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// httpCodes translates the statuses of the REST error mappers, so both
+// transports report an error the same way
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.Aborted,
+	http.StatusUnprocessableEntity: codes.FailedPrecondition,
+	http.StatusBadGateway:          codes.Unavailable,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+func mappedError(errorMapper mapper.ErrorMapper, err error) error {
+	errInf := errorMapper.MapError(err)
+
+	code, ok := httpCodes[errInf.StatusCode]
+	if !ok {
+		code = codes.Internal
+	}
+
+	return status.Error(code, errInf.Message)
+}
+
+func validationError(err error) error {
+	details, _ := json.Marshal(validation.FormatValidatorError(err).Body)
+
+	return status.Error(codes.InvalidArgument, string(details))
+}
@@ -0,0 +1,58 @@
+package grpcapi
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// Authenticator validates an access token and returns its login,
+// it is the same one the REST routes authenticate with
+type Authenticator interface {
+	Authenticate(accessToken string) (string, error)
+}
+
+type loginContextKey struct{}
+
+// AuthInterceptor requires a valid "authorization: Bearer <token>" metadata
+// entry on every method but the public ones
+func AuthInterceptor(authenticator Authenticator, publicMethods ...string) grpc.UnaryServerInterceptor {
+	public := make(map[string]struct{}, len(publicMethods))
+	for _, method := range publicMethods {
+		public[method] = struct{}{}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := public[info.FullMethod]; ok {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "no access token in metadata")
+		}
+
+		accessToken, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok || accessToken == "" {
+			return nil, status.Error(codes.Unauthenticated, "invalid token format")
+		}
+
+		login, err := authenticator.Authenticate(accessToken)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return handler(context.WithValue(ctx, loginContextKey{}, login), req)
+	}
+}
+
+func loginFromContext(ctx context.Context) string {
+	login, _ := ctx.Value(loginContextKey{}).(string)
+
+	return login
+}
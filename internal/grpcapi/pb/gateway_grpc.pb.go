@@ -0,0 +1,458 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: gateway.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AuthService_Login_FullMethodName   = "/gateway.v1.AuthService/Login"
+	AuthService_Refresh_FullMethodName = "/gateway.v1.AuthService/Refresh"
+)
+
+// AuthServiceClient is the client API for AuthService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuthServiceClient interface {
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+}
+
+type authServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthServiceClient(cc grpc.ClientConnInterface) AuthServiceClient {
+	return &authServiceClient{cc}
+}
+
+func (c *authServiceClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, AuthService_Login_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, AuthService_Refresh_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthServiceServer is the server API for AuthService service.
+// All implementations must embed UnimplementedAuthServiceServer
+// for forward compatibility
+type AuthServiceServer interface {
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	Refresh(context.Context, *RefreshRequest) (*LoginResponse, error)
+	mustEmbedUnimplementedAuthServiceServer()
+}
+
+// UnimplementedAuthServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAuthServiceServer struct {
+}
+
+func (UnimplementedAuthServiceServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedAuthServiceServer) Refresh(context.Context, *RefreshRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refresh not implemented")
+}
+func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
+
+// UnsafeAuthServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthServiceServer will
+// result in compilation errors.
+type UnsafeAuthServiceServer interface {
+	mustEmbedUnimplementedAuthServiceServer()
+}
+
+func RegisterAuthServiceServer(s grpc.ServiceRegistrar, srv AuthServiceServer) {
+	s.RegisterService(&AuthService_ServiceDesc, srv)
+}
+
+func _AuthService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_Refresh_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).Refresh(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_Refresh_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).Refresh(ctx, req.(*RefreshRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AuthService_ServiceDesc is the grpc.ServiceDesc for AuthService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AuthService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gateway.v1.AuthService",
+	HandlerType: (*AuthServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Login",
+			Handler:    _AuthService_Login_Handler,
+		},
+		{
+			MethodName: "Refresh",
+			Handler:    _AuthService_Refresh_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "gateway.proto",
+}
+
+const (
+	StoresService_CreateStore_FullMethodName        = "/gateway.v1.StoresService/CreateStore"
+	StoresService_CreateStoreVersion_FullMethodName = "/gateway.v1.StoresService/CreateStoreVersion"
+	StoresService_DeleteStore_FullMethodName        = "/gateway.v1.StoresService/DeleteStore"
+	StoresService_DeleteStoreVersion_FullMethodName = "/gateway.v1.StoresService/DeleteStoreVersion"
+	StoresService_GetStore_FullMethodName           = "/gateway.v1.StoresService/GetStore"
+	StoresService_GetStoreHistory_FullMethodName    = "/gateway.v1.StoresService/GetStoreHistory"
+	StoresService_GetStoreVersion_FullMethodName    = "/gateway.v1.StoresService/GetStoreVersion"
+)
+
+// StoresServiceClient is the client API for StoresService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type StoresServiceClient interface {
+	CreateStore(ctx context.Context, in *CreateStoreRequest, opts ...grpc.CallOption) (*Operation, error)
+	CreateStoreVersion(ctx context.Context, in *CreateStoreVersionRequest, opts ...grpc.CallOption) (*Operation, error)
+	DeleteStore(ctx context.Context, in *DeleteStoreRequest, opts ...grpc.CallOption) (*Operation, error)
+	DeleteStoreVersion(ctx context.Context, in *DeleteStoreVersionRequest, opts ...grpc.CallOption) (*Operation, error)
+	GetStore(ctx context.Context, in *GetStoreRequest, opts ...grpc.CallOption) (*StorageReply, error)
+	GetStoreHistory(ctx context.Context, in *GetStoreHistoryRequest, opts ...grpc.CallOption) (*StorageReply, error)
+	GetStoreVersion(ctx context.Context, in *GetStoreVersionRequest, opts ...grpc.CallOption) (*StorageReply, error)
+}
+
+type storesServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStoresServiceClient(cc grpc.ClientConnInterface) StoresServiceClient {
+	return &storesServiceClient{cc}
+}
+
+func (c *storesServiceClient) CreateStore(ctx context.Context, in *CreateStoreRequest, opts ...grpc.CallOption) (*Operation, error) {
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, StoresService_CreateStore_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storesServiceClient) CreateStoreVersion(ctx context.Context, in *CreateStoreVersionRequest, opts ...grpc.CallOption) (*Operation, error) {
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, StoresService_CreateStoreVersion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storesServiceClient) DeleteStore(ctx context.Context, in *DeleteStoreRequest, opts ...grpc.CallOption) (*Operation, error) {
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, StoresService_DeleteStore_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storesServiceClient) DeleteStoreVersion(ctx context.Context, in *DeleteStoreVersionRequest, opts ...grpc.CallOption) (*Operation, error) {
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, StoresService_DeleteStoreVersion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storesServiceClient) GetStore(ctx context.Context, in *GetStoreRequest, opts ...grpc.CallOption) (*StorageReply, error) {
+	out := new(StorageReply)
+	err := c.cc.Invoke(ctx, StoresService_GetStore_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storesServiceClient) GetStoreHistory(ctx context.Context, in *GetStoreHistoryRequest, opts ...grpc.CallOption) (*StorageReply, error) {
+	out := new(StorageReply)
+	err := c.cc.Invoke(ctx, StoresService_GetStoreHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storesServiceClient) GetStoreVersion(ctx context.Context, in *GetStoreVersionRequest, opts ...grpc.CallOption) (*StorageReply, error) {
+	out := new(StorageReply)
+	err := c.cc.Invoke(ctx, StoresService_GetStoreVersion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StoresServiceServer is the server API for StoresService service.
+// All implementations must embed UnimplementedStoresServiceServer
+// for forward compatibility
+type StoresServiceServer interface {
+	CreateStore(context.Context, *CreateStoreRequest) (*Operation, error)
+	CreateStoreVersion(context.Context, *CreateStoreVersionRequest) (*Operation, error)
+	DeleteStore(context.Context, *DeleteStoreRequest) (*Operation, error)
+	DeleteStoreVersion(context.Context, *DeleteStoreVersionRequest) (*Operation, error)
+	GetStore(context.Context, *GetStoreRequest) (*StorageReply, error)
+	GetStoreHistory(context.Context, *GetStoreHistoryRequest) (*StorageReply, error)
+	GetStoreVersion(context.Context, *GetStoreVersionRequest) (*StorageReply, error)
+	mustEmbedUnimplementedStoresServiceServer()
+}
+
+// UnimplementedStoresServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedStoresServiceServer struct {
+}
+
+func (UnimplementedStoresServiceServer) CreateStore(context.Context, *CreateStoreRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateStore not implemented")
+}
+func (UnimplementedStoresServiceServer) CreateStoreVersion(context.Context, *CreateStoreVersionRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateStoreVersion not implemented")
+}
+func (UnimplementedStoresServiceServer) DeleteStore(context.Context, *DeleteStoreRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteStore not implemented")
+}
+func (UnimplementedStoresServiceServer) DeleteStoreVersion(context.Context, *DeleteStoreVersionRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteStoreVersion not implemented")
+}
+func (UnimplementedStoresServiceServer) GetStore(context.Context, *GetStoreRequest) (*StorageReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStore not implemented")
+}
+func (UnimplementedStoresServiceServer) GetStoreHistory(context.Context, *GetStoreHistoryRequest) (*StorageReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStoreHistory not implemented")
+}
+func (UnimplementedStoresServiceServer) GetStoreVersion(context.Context, *GetStoreVersionRequest) (*StorageReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStoreVersion not implemented")
+}
+func (UnimplementedStoresServiceServer) mustEmbedUnimplementedStoresServiceServer() {}
+
+// UnsafeStoresServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StoresServiceServer will
+// result in compilation errors.
+type UnsafeStoresServiceServer interface {
+	mustEmbedUnimplementedStoresServiceServer()
+}
+
+func RegisterStoresServiceServer(s grpc.ServiceRegistrar, srv StoresServiceServer) {
+	s.RegisterService(&StoresService_ServiceDesc, srv)
+}
+
+func _StoresService_CreateStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateStoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoresServiceServer).CreateStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoresService_CreateStore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoresServiceServer).CreateStore(ctx, req.(*CreateStoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoresService_CreateStoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateStoreVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoresServiceServer).CreateStoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoresService_CreateStoreVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoresServiceServer).CreateStoreVersion(ctx, req.(*CreateStoreVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoresService_DeleteStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteStoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoresServiceServer).DeleteStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoresService_DeleteStore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoresServiceServer).DeleteStore(ctx, req.(*DeleteStoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoresService_DeleteStoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteStoreVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoresServiceServer).DeleteStoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoresService_DeleteStoreVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoresServiceServer).DeleteStoreVersion(ctx, req.(*DeleteStoreVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoresService_GetStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoresServiceServer).GetStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoresService_GetStore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoresServiceServer).GetStore(ctx, req.(*GetStoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoresService_GetStoreHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStoreHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoresServiceServer).GetStoreHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoresService_GetStoreHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoresServiceServer).GetStoreHistory(ctx, req.(*GetStoreHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoresService_GetStoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStoreVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoresServiceServer).GetStoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoresService_GetStoreVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoresServiceServer).GetStoreVersion(ctx, req.(*GetStoreVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StoresService_ServiceDesc is the grpc.ServiceDesc for StoresService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StoresService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gateway.v1.StoresService",
+	HandlerType: (*StoresServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateStore",
+			Handler:    _StoresService_CreateStore_Handler,
+		},
+		{
+			MethodName: "CreateStoreVersion",
+			Handler:    _StoresService_CreateStoreVersion_Handler,
+		},
+		{
+			MethodName: "DeleteStore",
+			Handler:    _StoresService_DeleteStore_Handler,
+		},
+		{
+			MethodName: "DeleteStoreVersion",
+			Handler:    _StoresService_DeleteStoreVersion_Handler,
+		},
+		{
+			MethodName: "GetStore",
+			Handler:    _StoresService_GetStore_Handler,
+		},
+		{
+			MethodName: "GetStoreHistory",
+			Handler:    _StoresService_GetStoreHistory_Handler,
+		},
+		{
+			MethodName: "GetStoreVersion",
+			Handler:    _StoresService_GetStoreVersion_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "gateway.proto",
+}
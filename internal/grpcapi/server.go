@@ -0,0 +1,58 @@
+// Package grpcapi serves the gRPC API described in api/proto/gateway.proto.
+// The pb package is generated from it with protoc-gen-go and protoc-gen-go-grpc:
+//
+//	protoc -I api/proto --go_out=internal/grpcapi/pb --go_opt=paths=source_relative \
+//		--go-grpc_out=internal/grpcapi/pb --go-grpc_opt=paths=source_relative gateway.proto
+package grpcapi
+
+import (
+	"GatewayService/internal/grpcapi/pb"
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"net"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type Server struct {
+	grpcServer *grpc.Server
+	addr       string
+	logger     *zap.Logger
+}
+
+func NewServer(addr string, authServer *AuthServer, storesServer *StoresServer, authenticator Authenticator, logger *zap.Logger) *Server {
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(AuthInterceptor(authenticator, pb.AuthService_Login_FullMethodName, pb.AuthService_Refresh_FullMethodName)),
+	)
+
+	pb.RegisterAuthServiceServer(grpcServer, authServer)
+	pb.RegisterStoresServiceServer(grpcServer, storesServer)
+
+	return &Server{
+		grpcServer: grpcServer,
+		addr:       addr,
+		logger:     logger,
+	}
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	g, _ := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		s.logger.Info("gRPC server is running")
+		return s.grpcServer.Serve(listener)
+	})
+
+	g.Go(func() error {
+		<-ctx.Done()
+		s.grpcServer.GracefulStop()
+		return nil
+	})
+
+	return g.Wait()
+}
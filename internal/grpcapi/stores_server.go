@@ -0,0 +1,137 @@
+package grpcapi
+
+import (
+	"GatewayService/internal/grpcapi/pb"
+	"GatewayService/internal/handler"
+	"GatewayService/internal/handler/mapper"
+	"GatewayService/internal/message"
+	"GatewayService/internal/middleware"
+	"GatewayService/internal/operation"
+	"context"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+const idempotencyKeyMetadata = "idempotency-key"
+
+// StoresServer serves the storage routes over gRPC through the StoresService
+// and validators of the REST handler
+type StoresServer struct {
+	pb.UnimplementedStoresServiceServer
+
+	stores          handler.StoresService
+	structValidator *validator.Validate
+	errorMapper     mapper.ErrorMapper
+}
+
+func NewStoresServer(stores handler.StoresService, structValidator *validator.Validate, errorMapper mapper.ErrorMapper) *StoresServer {
+	return &StoresServer{
+		stores:          stores,
+		structValidator: structValidator,
+		errorMapper:     errorMapper,
+	}
+}
+
+func (s *StoresServer) CreateStore(ctx context.Context, req *pb.CreateStoreRequest) (*pb.Operation, error) {
+	store := handler.Store{
+		Name:        req.GetStore().GetName(),
+		Address:     req.GetStore().GetAddress(),
+		OwnerName:   req.GetStore().GetOwnerName(),
+		OpeningTime: req.GetStore().GetOpeningTime(),
+		ClosingTime: req.GetStore().GetClosingTime(),
+	}
+
+	if err := s.structValidator.Struct(store); err != nil {
+		return nil, validationError(err)
+	}
+
+	return s.startOperation(ctx, message.CreateStore, "", "", store)
+}
+
+func (s *StoresServer) CreateStoreVersion(ctx context.Context, req *pb.CreateStoreVersionRequest) (*pb.Operation, error) {
+	storeVersion := handler.StoreVersion{
+		OwnerName:   req.GetVersion().GetOwnerName(),
+		OpeningTime: req.GetVersion().GetOpeningTime(),
+		ClosingTime: req.GetVersion().GetClosingTime(),
+	}
+
+	if err := s.structValidator.Struct(storeVersion); err != nil {
+		return nil, validationError(err)
+	}
+
+	return s.startOperation(ctx, message.CreateStoreVersion, req.GetStoreId(), "", storeVersion)
+}
+
+func (s *StoresServer) DeleteStore(ctx context.Context, req *pb.DeleteStoreRequest) (*pb.Operation, error) {
+	return s.startOperation(ctx, message.DeleteStore, req.GetStoreId(), "", nil)
+}
+
+func (s *StoresServer) DeleteStoreVersion(ctx context.Context, req *pb.DeleteStoreVersionRequest) (*pb.Operation, error) {
+	return s.startOperation(ctx, message.DeleteStoreVersion, req.GetStoreId(), req.GetVersionId(), nil)
+}
+
+func (s *StoresServer) GetStore(ctx context.Context, req *pb.GetStoreRequest) (*pb.StorageReply, error) {
+	return s.request(ctx, message.GetStore, req.GetStoreId(), "")
+}
+
+func (s *StoresServer) GetStoreHistory(ctx context.Context, req *pb.GetStoreHistoryRequest) (*pb.StorageReply, error) {
+	return s.request(ctx, message.GetStoreHistory, req.GetStoreId(), "")
+}
+
+func (s *StoresServer) GetStoreVersion(ctx context.Context, req *pb.GetStoreVersionRequest) (*pb.StorageReply, error) {
+	return s.request(ctx, message.GetStoreVersion, req.GetStoreId(), req.GetVersionId())
+}
+
+func (s *StoresServer) startOperation(ctx context.Context, action message.Action, storeId, versionId string, data interface{}) (*pb.Operation, error) {
+	idempotencyKey, err := idempotencyKeyFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := s.stores.StartOperation(ctx, loginFromContext(ctx), action, storeId, versionId, data, idempotencyKey)
+	if err != nil {
+		return nil, mappedError(s.errorMapper, err)
+	}
+
+	return toOperation(op), nil
+}
+
+func (s *StoresServer) request(ctx context.Context, action message.Action, storeId, versionId string) (*pb.StorageReply, error) {
+	body, err := s.stores.Request(ctx, loginFromContext(ctx), action, storeId, versionId, nil)
+	if err != nil {
+		return nil, mappedError(s.errorMapper, err)
+	}
+
+	return &pb.StorageReply{Json: body}, nil
+}
+
+// idempotencyKeyFromContext reads the key of a mutation from the
+// "idempotency-key" metadata entry, the counterpart of the REST header
+func idempotencyKeyFromContext(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(idempotencyKeyMetadata)
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	if len(values[0]) > middleware.MaxIdempotencyKeyLength {
+		return "", status.Errorf(codes.InvalidArgument, "idempotency-key must not be longer than %d characters", middleware.MaxIdempotencyKeyLength)
+	}
+
+	return values[0], nil
+}
+
+func toOperation(op operation.Operation) *pb.Operation {
+	return &pb.Operation{
+		Id:        op.ID,
+		Action:    op.Action,
+		StoreId:   op.StoreID,
+		VersionId: op.VersionID,
+		Status:    string(op.Status),
+		CreatedAt: op.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,54 @@
+package grpcapi
+
+import (
+	"GatewayService/internal/grpcapi/pb"
+	"GatewayService/internal/handler/mapper"
+	"GatewayService/internal/message"
+	"GatewayService/internal/operation"
+	"context"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+// keyRecorder keeps the idempotency key of the last started operation
+type keyRecorder struct {
+	idempotencyKey string
+}
+
+func (r *keyRecorder) StartOperation(_ context.Context, _ string, _ message.Action, _, _ string, _ interface{}, idempotencyKey string) (operation.Operation, error) {
+	r.idempotencyKey = idempotencyKey
+	return operation.Operation{ID: "op-1"}, nil
+}
+
+func (r *keyRecorder) Request(context.Context, string, message.Action, string, string, interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+func TestStoresServerForwardsIdempotencyKey(t *testing.T) {
+	stores := &keyRecorder{}
+	s := NewStoresServer(stores, validator.New(), mapper.NewStoresErrorMapper())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "delete-1"))
+	if _, err := s.DeleteStore(ctx, &pb.DeleteStoreRequest{StoreId: "store-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if stores.idempotencyKey != "delete-1" {
+		t.Errorf("forwarded key %q, want %q", stores.idempotencyKey, "delete-1")
+	}
+
+	if _, err := s.DeleteStore(context.Background(), &pb.DeleteStoreRequest{StoreId: "store-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if stores.idempotencyKey != "" {
+		t.Errorf("forwarded key %q without metadata", stores.idempotencyKey)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", strings.Repeat("k", 256)))
+	if _, err := s.DeleteStore(ctx, &pb.DeleteStoreRequest{StoreId: "store-1"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("long key: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/outbox"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+type OutboxStatsSource interface {
+	Stats() outbox.Stats
+}
+
+type AdminHandler struct {
+	outbox OutboxStatsSource
+}
+
+type outboxView struct {
+	Enabled          bool       `json:"enabled"`
+	Depth            int        `json:"depth"`
+	Bytes            int64      `json:"bytes"`
+	MaxBytes         int64      `json:"maxBytes"`
+	OldestEnqueuedAt *time.Time `json:"oldestEnqueuedAt,omitempty"`
+	OldestAgeSeconds float64    `json:"oldestAgeSeconds"`
+}
+
+// NewAdminHandler accepts a nil outbox when the outbox mode is disabled
+func NewAdminHandler(outbox OutboxStatsSource) *AdminHandler {
+	return &AdminHandler{
+		outbox: outbox,
+	}
+}
+
+func (h *AdminHandler) OutboxStats(c *gin.Context) {
+	if h.outbox == nil {
+		c.JSON(http.StatusOK, response.BuildJSONResponse("Success", outboxView{Enabled: false}))
+		return
+	}
+
+	stats := h.outbox.Stats()
+
+	view := outboxView{
+		Enabled:  true,
+		Depth:    stats.Depth,
+		Bytes:    stats.Bytes,
+		MaxBytes: stats.MaxBytes,
+	}
+
+	if !stats.OldestAt.IsZero() {
+		view.OldestEnqueuedAt = &stats.OldestAt
+		view.OldestAgeSeconds = time.Since(stats.OldestAt).Seconds()
+	}
+
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", view))
+}
@@ -4,14 +4,23 @@ import (
 	"GatewayService/internal/handler/mapper"
 	"GatewayService/internal/handler/response"
 	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/jwtauth"
+	"GatewayService/internal/middleware"
 	"GatewayService/internal/service"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"time"
 )
 
 type AuthService interface {
-	SignIn(user service.User) (string, error)
+	SignIn(ctx context.Context, user service.User) (service.Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (service.Tokens, error)
+	Logout(ctx context.Context, login, tokenId string, expiresAt time.Time, refreshToken string) error
+	RevokeLogin(ctx context.Context, login string) error
 }
 
 type AuthHandler struct {
@@ -25,6 +34,19 @@ type Auth struct {
 	Password string `json:"password" binding:"required,min=6,max=40"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required,max=100"`
+}
+
+// LogoutRequest may name the refresh token to revoke along with the access token
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"max=100"`
+}
+
+type RevokeRequest struct {
+	Login string `json:"login" binding:"required,min=3,max=50"`
+}
+
 func NewAuthHandler(authService AuthService, logger *zap.Logger, mapper mapper.ErrorMapper) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
@@ -46,7 +68,7 @@ func (h *AuthHandler) SingIn(c *gin.Context) {
 		Password: credentials.Password,
 	}
 
-	accessToken, err := h.authService.SignIn(user)
+	tokens, err := h.authService.SignIn(c.Request.Context(), user)
 
 	if err != nil {
 		h.logger.With(
@@ -66,5 +88,98 @@ func (h *AuthHandler) SingIn(c *gin.Context) {
 		zap.String("token", "accessToken"),
 	).Info("Token generated successfully")
 
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Access token", accessToken))
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Tokens", tokens))
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	tokens, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		h.logger.With(
+			zap.String("place", "authHandler"),
+			zap.String("func", "Refresh"),
+		).Error("Error while refreshing tokens: " + err.Error())
+
+		errInf := h.errorMapper.MapError(err)
+
+		c.JSON(errInf.StatusCode,
+			response.BuildJSONResponse("Error", errInf.Message))
+
+		return
+	}
+
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Tokens", tokens))
+}
+
+// Logout must run after AccessTokenValidation, it revokes the access token
+// the request was made with
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	var expiresAt time.Time
+	if claims, ok := c.Value(middleware.ClaimsKey).(*jwtauth.Claims); ok && claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	login := c.GetString("login")
+
+	err := h.authService.Logout(c.Request.Context(), login, c.GetString(middleware.TokenIDKey), expiresAt, req.RefreshToken)
+	if err != nil {
+		h.logger.With(
+			zap.String("place", "authHandler"),
+			zap.String("func", "Logout"),
+		).Error("Error while logging out: " + err.Error())
+
+		errInf := h.errorMapper.MapError(err)
+
+		c.JSON(errInf.StatusCode,
+			response.BuildJSONResponse("Error", errInf.Message))
+
+		return
+	}
+
+	h.logger.With(
+		zap.String("login", login),
+	).Info("User logged out")
+
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", "Logged out"))
+}
+
+// Revoke must run after AdminOnly, it revokes every token issued to a login
+func (h *AuthHandler) Revoke(c *gin.Context) {
+	var req RevokeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	if err := h.authService.RevokeLogin(c.Request.Context(), req.Login); err != nil {
+		h.logger.With(
+			zap.String("place", "authHandler"),
+			zap.String("func", "Revoke"),
+		).Error("Error while revoking tokens: " + err.Error())
+
+		errInf := h.errorMapper.MapError(err)
+
+		c.JSON(errInf.StatusCode,
+			response.BuildJSONResponse("Error", errInf.Message))
+
+		return
+	}
+
+	h.logger.With(
+		zap.String("login", req.Login),
+		zap.String("admin", c.GetString("login")),
+	).Warn("Tokens of login revoked")
+
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", "Tokens of "+req.Login+" revoked"))
 }
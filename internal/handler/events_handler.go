@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"GatewayService/internal/events"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const LastEventIDHeader = "Last-Event-ID"
+
+type EventSubscriber interface {
+	Subscribe(login string, lastEventID uint64) (*events.Subscription, []events.Event)
+	Unsubscribe(sub *events.Subscription)
+}
+
+type EventsHandler struct {
+	hub               EventSubscriber
+	heartbeatInterval time.Duration
+	upgrader          websocket.Upgrader
+	logger            *zap.Logger
+}
+
+type wsEvent struct {
+	ID    string      `json:"id"`
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+func NewEventsHandler(hub EventSubscriber, heartbeatInterval time.Duration, logger *zap.Logger) *EventsHandler {
+	return &EventsHandler{
+		hub:               hub,
+		heartbeatInterval: heartbeatInterval,
+		logger:            logger,
+	}
+}
+
+// Stream pushes the operation results of the current user as Server-Sent Events
+func (h *EventsHandler) Stream(c *gin.Context) {
+	login := c.GetString("login")
+
+	sub, missed := h.hub.Subscribe(login, lastEventID(c))
+	defer h.hub.Unsubscribe(sub)
+
+	// the stream outlives the server write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.With(
+			zap.String("place", "EventsHandler"),
+			zap.Error(err),
+		).Warn("Failed to clear write deadline for event stream")
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, event := range missed {
+		writeSSE(c, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			writeSSE(c, event)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// WebSocket is the WebSocket variant of Stream. Heartbeats are sent as ping frames
+func (h *EventsHandler) WebSocket(c *gin.Context) {
+	login := c.GetString("login")
+	lastID := lastEventID(c)
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already replied to the client
+		return
+	}
+	defer conn.Close()
+
+	sub, missed := h.hub.Subscribe(login, lastID)
+	defer h.hub.Unsubscribe(sub)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		// drain the client frames so pongs and close messages are handled
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range missed {
+		if err := writeWS(conn, event); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"))
+				return
+			}
+			if err := writeWS(conn, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			deadline := time.Now().Add(h.heartbeatInterval)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeSSE(c *gin.Context, event events.Event) {
+	fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.IDString(), event.Type, event.Data)
+}
+
+func writeWS(conn *websocket.Conn, event events.Event) error {
+	return conn.WriteJSON(wsEvent{
+		ID:    event.IDString(),
+		Event: event.Type,
+		Data:  event.Data,
+	})
+}
+
+// lastEventID reads the reconnection point from the Last-Event-ID header or,
+// for clients that cannot set headers, from the lastEventId query parameter
+func lastEventID(c *gin.Context) uint64 {
+	raw := c.GetHeader(LastEventIDHeader)
+	if raw == "" {
+		raw = c.Query("lastEventId")
+	}
+
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
@@ -0,0 +1,403 @@
+package handler
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/handler/mapper"
+	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/message"
+	"GatewayService/internal/middleware"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query" form:"query" binding:"required"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName" form:"operationName"`
+}
+
+// GraphQLHandler serves the stores over GraphQL. Queries and mutations go
+// through the same StoresService as the REST routes
+type GraphQLHandler struct {
+	schema          graphql.Schema
+	stores          StoresService
+	structValidator *validator.Validate
+	errorMapper     mapper.ErrorMapper
+	limits          queryLimits
+	logger          *zap.Logger
+}
+
+func NewGraphQLHandler(stores StoresService, structValidator *validator.Validate, errorMapper mapper.ErrorMapper, maxDepth, maxComplexity int, logger *zap.Logger) (*GraphQLHandler, error) {
+	h := &GraphQLHandler{
+		stores:          stores,
+		structValidator: structValidator,
+		errorMapper:     errorMapper,
+		limits:          queryLimits{maxDepth: maxDepth, maxComplexity: maxComplexity},
+		logger:          logger,
+	}
+
+	schema, err := h.buildSchema()
+	if err != nil {
+		return nil, err
+	}
+	h.schema = schema
+
+	return h, nil
+}
+
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var request GraphQLRequest
+
+	var err error
+	if c.Request.Method == http.MethodGet {
+		err = c.ShouldBindQuery(&request)
+		if variables := c.Query("variables"); err == nil && variables != "" {
+			err = json.Unmarshal([]byte(variables), &request.Variables)
+		}
+	} else {
+		err = c.ShouldBindJSON(&request)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, graphqlErrors("Request must carry a GraphQL query"))
+		return
+	}
+
+	parsed, err := parseRequest(request.Query, request.OperationName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, graphqlErrors(err.Error()))
+		return
+	}
+
+	// GET requests may be sent by a link or cached, so they must not mutate
+	if c.Request.Method == http.MethodGet && !parsed.onlyQueries() {
+		c.Header("Allow", http.MethodPost)
+		c.JSON(http.StatusMethodNotAllowed, graphqlErrors("Mutations must be sent with POST"))
+		return
+	}
+
+	if err := h.limits.check(parsed); err != nil {
+		c.JSON(http.StatusBadRequest, graphqlErrors(err.Error()))
+		return
+	}
+
+	idempotencyKey := c.GetHeader(middleware.IdempotencyKeyHeader)
+	if len(idempotencyKey) > middleware.MaxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, graphqlErrors("Idempotency-Key must not be longer than 255 characters"))
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), loaderContextKey{},
+		newStoreLoader(c.Request.Context(), c.GetString("login"), h.stores))
+	ctx = context.WithValue(ctx, idempotencyContextKey{}, idempotencyKey)
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        ctx,
+	})
+
+	c.JSON(http.StatusOK, result)
+}
+
+type idempotencyContextKey struct{}
+
+func graphqlErrors(message string) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(message)}}
+}
+
+func (h *GraphQLHandler) buildSchema() (graphql.Schema, error) {
+	storeVersionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "StoreVersion",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.ID},
+			"ownerName":   &graphql.Field{Type: graphql.String},
+			"openingTime": &graphql.Field{Type: graphql.String},
+			"closingTime": &graphql.Field{Type: graphql.String},
+			"deleted":     &graphql.Field{Type: graphql.Boolean},
+		},
+	})
+
+	storeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Store",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":        &graphql.Field{Type: graphql.String},
+			"address":     &graphql.Field{Type: graphql.String},
+			"ownerName":   &graphql.Field{Type: graphql.String},
+			"openingTime": &graphql.Field{Type: graphql.String},
+			"closingTime": &graphql.Field{Type: graphql.String},
+			"history": &graphql.Field{
+				Type: graphql.NewList(storeVersionType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.history(p.Context, sourceID(p.Source))
+				},
+			},
+			"version": &graphql.Field{
+				Type: storeVersionType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.version(p.Context, sourceID(p.Source), p.Args["id"].(string))
+				},
+			},
+		},
+	})
+
+	operationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Operation",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"action":    &graphql.Field{Type: graphql.String},
+			"storeId":   &graphql.Field{Type: graphql.ID},
+			"versionId": &graphql.Field{Type: graphql.ID},
+			"status":    &graphql.Field{Type: graphql.String},
+			"createdAt": &graphql.Field{Type: graphql.DateTime},
+			"updatedAt": &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	storeInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "StoreInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"address":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"ownerName":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"openingTime": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"closingTime": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	storeVersionInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "StoreVersionInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"ownerName":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"openingTime": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"closingTime": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	idArg := func(names ...string) graphql.FieldConfigArgument {
+		args := graphql.FieldConfigArgument{}
+		for _, name := range names {
+			args[name] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}
+		}
+		return args
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"store": &graphql.Field{
+				Type: storeType,
+				Args: idArg("id"),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.store(p.Context, p.Args["id"].(string))
+				},
+			},
+			"storeHistory": &graphql.Field{
+				Type: graphql.NewList(storeVersionType),
+				Args: idArg("storeId"),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.history(p.Context, p.Args["storeId"].(string))
+				},
+			},
+			"storeVersion": &graphql.Field{
+				Type: storeVersionType,
+				Args: idArg("storeId", "versionId"),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.version(p.Context, p.Args["storeId"].(string), p.Args["versionId"].(string))
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createStore": &graphql.Field{
+				Type: operationType,
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(storeInputType)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var store Store
+					if err := h.decodeInput(p.Args["input"], &store); err != nil {
+						return nil, err
+					}
+					return h.startOperation(p, message.CreateStore, "", "", store)
+				},
+			},
+			"createStoreVersion": &graphql.Field{
+				Type: operationType,
+				Args: graphql.FieldConfigArgument{
+					"storeId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(storeVersionInputType)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var storeVersion StoreVersion
+					if err := h.decodeInput(p.Args["input"], &storeVersion); err != nil {
+						return nil, err
+					}
+					return h.startOperation(p, message.CreateStoreVersion, p.Args["storeId"].(string), "", storeVersion)
+				},
+			},
+			"deleteStore": &graphql.Field{
+				Type: operationType,
+				Args: idArg("id"),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.startOperation(p, message.DeleteStore, p.Args["id"].(string), "", nil)
+				},
+			},
+			"deleteStoreVersion": &graphql.Field{
+				Type: operationType,
+				Args: idArg("storeId", "versionId"),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.startOperation(p, message.DeleteStoreVersion, p.Args["storeId"].(string), p.Args["versionId"].(string), nil)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+// decodeInput fills target from a GraphQL input object and validates it
+// like the REST body of the same action
+func (h *GraphQLHandler) decodeInput(input interface{}, target interface{}) error {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return err
+	}
+
+	if err := h.structValidator.Struct(target); err != nil {
+		details, _ := json.Marshal(validation.FormatValidatorError(err).Body)
+		return fmt.Errorf("invalid input: %s", details)
+	}
+
+	return nil
+}
+
+// startOperation derives the idempotency key of every mutation field from the
+// Idempotency-Key header and the field's response key, so a document with
+// several mutations can be retried as a whole
+func (h *GraphQLHandler) startOperation(p graphql.ResolveParams, action message.Action, storeId, versionId string, data interface{}) (interface{}, error) {
+	loader := p.Context.Value(loaderContextKey{}).(*storeLoader)
+
+	var idempotencyKey string
+	if key, _ := p.Context.Value(idempotencyContextKey{}).(string); key != "" {
+		idempotencyKey = fmt.Sprintf("%s:%v", key, p.Info.Path.Key)
+	}
+
+	op, err := h.stores.StartOperation(p.Context, loader.login, action, storeId, versionId, data, idempotencyKey)
+	if err != nil {
+		return nil, errors.New(h.errorMapper.MapError(err).Message)
+	}
+
+	return op, nil
+}
+
+// store, history and version return thunks, graphql-go resolves them once
+// every field of the level was visited so the loader sees all the store IDs
+func (h *GraphQLHandler) store(ctx context.Context, storeId string) (interface{}, error) {
+	wait := h.load(ctx, message.GetStore, storeId, "")
+
+	return func() (interface{}, error) {
+		reply, err := wait()
+		if err != nil {
+			return nil, err
+		}
+
+		store, ok := reply.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		if _, ok := store["id"]; !ok {
+			store["id"] = storeId
+		}
+
+		return store, nil
+	}, nil
+}
+
+func (h *GraphQLHandler) history(ctx context.Context, storeId string) (interface{}, error) {
+	wait := h.load(ctx, message.GetStoreHistory, storeId, "")
+
+	return func() (interface{}, error) {
+		reply, err := wait()
+		if err != nil {
+			return nil, err
+		}
+
+		// the history is either a plain list or wrapped as {"versions": [...]}
+		if wrapped, ok := reply.(map[string]interface{}); ok {
+			reply = wrapped["versions"]
+		}
+
+		versions, _ := reply.([]interface{})
+		return versions, nil
+	}, nil
+}
+
+func (h *GraphQLHandler) version(ctx context.Context, storeId, versionId string) (interface{}, error) {
+	wait := h.load(ctx, message.GetStoreVersion, storeId, versionId)
+
+	return func() (interface{}, error) {
+		reply, err := wait()
+		if err != nil {
+			return nil, err
+		}
+
+		version, ok := reply.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		if _, ok := version["id"]; !ok {
+			version["id"] = versionId
+		}
+
+		return version, nil
+	}, nil
+}
+
+func (h *GraphQLHandler) load(ctx context.Context, action message.Action, storeId, versionId string) func() (interface{}, error) {
+	loader := ctx.Value(loaderContextKey{}).(*storeLoader)
+
+	wait := loader.load(action, storeId, versionId)
+
+	return func() (interface{}, error) {
+		reply, err := wait()
+		if errors.Is(err, bus.ErrNotFound) {
+			// resolves the field to null
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.New(h.errorMapper.MapError(err).Message)
+		}
+
+		return reply, nil
+	}
+}
+
+func sourceID(source interface{}) string {
+	store, _ := source.(map[string]interface{})
+	id, _ := store["id"].(string)
+
+	return id
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/handler/mapper"
+	"GatewayService/internal/message"
+	"GatewayService/internal/operation"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingStores answers the reads of known stores and counts the requests
+// sent for every store
+type countingStores struct {
+	mu       sync.Mutex
+	stores   map[string]string
+	requests map[string]int
+}
+
+func (s *countingStores) StartOperation(context.Context, string, message.Action, string, string, interface{}, string) (operation.Operation, error) {
+	return operation.Operation{}, errors.New("mutations are not served")
+}
+
+func (s *countingStores) Request(_ context.Context, _ string, _ message.Action, storeId, _ string, _ interface{}) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[storeId]++
+
+	name, ok := s.stores[storeId]
+	if !ok {
+		return nil, bus.ErrNotFound
+	}
+
+	return json.Marshal(map[string]string{"id": storeId, "name": name})
+}
+
+type graphqlFixture struct {
+	router *gin.Engine
+	stores *countingStores
+}
+
+func newGraphQLFixture(t *testing.T, maxDepth, maxComplexity int) graphqlFixture {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	stores := &countingStores{
+		stores:   map[string]string{"s1": "First", "s2": "Second"},
+		requests: make(map[string]int),
+	}
+
+	h, err := NewGraphQLHandler(stores, validator.New(), mapper.NewStoresErrorMapper(), maxDepth, maxComplexity, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/graphql", h.Query)
+	router.POST("/graphql", h.Query)
+
+	return graphqlFixture{router: router, stores: stores}
+}
+
+func (f graphqlFixture) post(t *testing.T, query string) (int, map[string]interface{}) {
+	t.Helper()
+
+	body, err := json.Marshal(GraphQLRequest{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	return f.serve(t, req)
+}
+
+func (f graphqlFixture) get(t *testing.T, query, operationName string) (int, map[string]interface{}) {
+	t.Helper()
+
+	params := url.Values{"query": {query}}
+	if operationName != "" {
+		params.Set("operationName", operationName)
+	}
+
+	return f.serve(t, httptest.NewRequest(http.MethodGet, "/graphql?"+params.Encode(), nil))
+}
+
+func (f graphqlFixture) serve(t *testing.T, req *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+
+	var reply map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("unreadable reply %q", rec.Body.String())
+	}
+
+	return rec.Code, reply
+}
+
+func TestGraphQLRejectsOverLimitQueries(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxDepth      int
+		maxComplexity int
+		query         string
+		want          string
+	}{
+		{"depth", 2, 0, `{ store(id: "s1") { history { id } } }`, "depth"},
+		{"depth through a fragment", 2, 0, `{ store(id: "s1") { ...withHistory } } fragment withHistory on Store { history { id } }`, "depth"},
+		{"complexity", 0, 25, `{ a: store(id: "s1") { name } b: store(id: "s2") { name } c: store(id: "s3") { name } }`, "complexity"},
+	}
+
+	for _, tt := range tests {
+		f := newGraphQLFixture(t, tt.maxDepth, tt.maxComplexity)
+
+		code, reply := f.post(t, tt.query)
+		if code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", tt.name, code, http.StatusBadRequest)
+			continue
+		}
+
+		errs, _ := reply["errors"].([]interface{})
+		if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), tt.want) {
+			t.Errorf("%s: errors %v do not mention the %s", tt.name, reply["errors"], tt.want)
+		}
+
+		if len(f.stores.requests) != 0 {
+			t.Errorf("%s: rejected query was resolved", tt.name)
+		}
+	}
+}
+
+func TestGraphQLAcceptsQueryWithinLimits(t *testing.T) {
+	f := newGraphQLFixture(t, 2, 25)
+
+	code, reply := f.post(t, `{ store(id: "s1") { name } }`)
+	if code != http.StatusOK || reply["errors"] != nil {
+		t.Fatalf("status %d, reply %v", code, reply)
+	}
+}
+
+func TestGraphQLGetAllowsOnlyQueries(t *testing.T) {
+	f := newGraphQLFixture(t, 0, 0)
+
+	code, reply := f.get(t, `{ store(id: "s1") { name } }`, "")
+	if code != http.StatusOK || reply["errors"] != nil {
+		t.Fatalf("query over GET: status %d, reply %v", code, reply)
+	}
+
+	code, _ = f.get(t, `mutation { deleteStore(id: "s1") { id } }`, "")
+	if code != http.StatusMethodNotAllowed {
+		t.Errorf("mutation over GET: status %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+
+	document := `query read { store(id: "s1") { name } } mutation drop { deleteStore(id: "s1") { id } }`
+
+	// only the selected operation counts
+	code, reply = f.get(t, document, "read")
+	if code != http.StatusOK || reply["errors"] != nil {
+		t.Errorf("query next to a mutation over GET: status %d, reply %v", code, reply)
+	}
+
+	code, _ = f.get(t, document, "drop")
+	if code != http.StatusMethodNotAllowed {
+		t.Errorf("mutation next to a query over GET: status %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestGraphQLLoaderMergesLookups(t *testing.T) {
+	f := newGraphQLFixture(t, 0, 0)
+
+	code, reply := f.post(t, `{ a: store(id: "s1") { name } b: store(id: "s1") { id } c: store(id: "s2") { name } }`)
+	if code != http.StatusOK || reply["errors"] != nil {
+		t.Fatalf("status %d, reply %v", code, reply)
+	}
+
+	data := reply["data"].(map[string]interface{})
+	if data["a"].(map[string]interface{})["name"] != "First" || data["c"].(map[string]interface{})["name"] != "Second" {
+		t.Errorf("data %v", data)
+	}
+
+	if f.stores.requests["s1"] != 1 || f.stores.requests["s2"] != 1 {
+		t.Errorf("requests %v, want one per store", f.stores.requests)
+	}
+}
+
+func TestGraphQLUnknownStoreIsNull(t *testing.T) {
+	f := newGraphQLFixture(t, 0, 0)
+
+	code, reply := f.post(t, `{ store(id: "missing") { name } }`)
+	if code != http.StatusOK || reply["errors"] != nil {
+		t.Fatalf("status %d, reply %v", code, reply)
+	}
+
+	data := reply["data"].(map[string]interface{})
+	if store, ok := data["store"]; !ok || store != nil {
+		t.Errorf("store resolved to %v, want null", data["store"])
+	}
+}
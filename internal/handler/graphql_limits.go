@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// storageFieldCost is the complexity of a field resolved by a request to the
+// storage service, any other field costs 1
+const storageFieldCost = 10
+
+var storageFields = map[string]bool{
+	"store":              true,
+	"storeHistory":       true,
+	"storeVersion":       true,
+	"history":            true,
+	"version":            true,
+	"createStore":        true,
+	"createStoreVersion": true,
+	"deleteStore":        true,
+	"deleteStoreVersion": true,
+}
+
+// queryLimits rejects queries nesting deeper than maxDepth or costing more
+// than maxComplexity before anything is resolved. Zero disables a limit
+type queryLimits struct {
+	maxDepth      int
+	maxComplexity int
+}
+
+// parsedRequest holds the operations a request selects and the fragments
+// they may spread
+type parsedRequest struct {
+	operations []*ast.OperationDefinition
+	fragments  map[string]*ast.FragmentDefinition
+}
+
+// parseRequest selects the operation named operationName, or every operation
+// of the document when no name is given
+func parseRequest(query, operationName string) (parsedRequest, error) {
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return parsedRequest{}, err
+	}
+
+	request := parsedRequest{fragments: make(map[string]*ast.FragmentDefinition)}
+
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			request.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				request.operations = append(request.operations, definition)
+			}
+		}
+	}
+
+	return request, nil
+}
+
+// onlyQueries reports whether the request reads without mutating anything
+func (r parsedRequest) onlyQueries() bool {
+	for _, operation := range r.operations {
+		if operation.Operation != ast.OperationTypeQuery {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l queryLimits) check(request parsedRequest) error {
+	for _, operation := range request.operations {
+		depth, complexity := measure(operation.SelectionSet, request.fragments, map[string]bool{})
+
+		if l.maxDepth > 0 && depth > l.maxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, l.maxDepth)
+		}
+		if l.maxComplexity > 0 && complexity > l.maxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, l.maxComplexity)
+		}
+	}
+
+	return nil
+}
+
+// measure returns the depth and complexity of a selection set, following
+// fragments. visiting guards against fragments spreading themselves
+func measure(selectionSet *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, visiting map[string]bool) (int, int) {
+	if selectionSet == nil {
+		return 0, 0
+	}
+
+	depth, complexity := 0, 0
+
+	for _, selection := range selectionSet.Selections {
+		var childDepth, childComplexity int
+
+		switch selection := selection.(type) {
+		case *ast.Field:
+			childDepth, childComplexity = measure(selection.SelectionSet, fragments, visiting)
+			childDepth++
+			if storageFields[selection.Name.Value] {
+				childComplexity += storageFieldCost
+			} else {
+				childComplexity++
+			}
+		case *ast.InlineFragment:
+			childDepth, childComplexity = measure(selection.SelectionSet, fragments, visiting)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := fragments[name]
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			childDepth, childComplexity = measure(fragment.SelectionSet, fragments, visiting)
+			delete(visiting, name)
+		}
+
+		if childDepth > depth {
+			depth = childDepth
+		}
+		complexity += childComplexity
+	}
+
+	return depth, complexity
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"GatewayService/internal/message"
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+type loaderContextKey struct{}
+
+type loaderCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// storeLoader batches the reads of a single GraphQL request: a store, its
+// history or a version asked for several times is requested from the
+// storage service once, and different ones are requested concurrently
+type storeLoader struct {
+	ctx    context.Context
+	login  string
+	stores StoresService
+
+	mu    sync.Mutex
+	calls map[string]*loaderCall
+}
+
+func newStoreLoader(ctx context.Context, login string, stores StoresService) *storeLoader {
+	return &storeLoader{
+		ctx:    ctx,
+		login:  login,
+		stores: stores,
+		calls:  make(map[string]*loaderCall),
+	}
+}
+
+// load starts the read unless it is already running and returns a function
+// waiting for its decoded reply
+func (l *storeLoader) load(action message.Action, storeId, versionId string) func() (interface{}, error) {
+	key := string(action) + ":" + storeId + ":" + versionId
+
+	l.mu.Lock()
+	call, ok := l.calls[key]
+	if !ok {
+		call = &loaderCall{done: make(chan struct{})}
+		l.calls[key] = call
+		go l.fetch(call, action, storeId, versionId)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		<-call.done
+		return call.value, call.err
+	}
+}
+
+func (l *storeLoader) fetch(call *loaderCall, action message.Action, storeId, versionId string) {
+	defer close(call.done)
+
+	body, err := l.stores.Request(l.ctx, l.login, action, storeId, versionId, nil)
+	if err != nil {
+		call.err = err
+		return
+	}
+
+	if err := json.Unmarshal(body, &call.value); err != nil {
+		call.err = errUnexpectedStore
+	}
+}
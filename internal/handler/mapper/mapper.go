@@ -1,17 +1,41 @@
 package mapper
 
 import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/message"
+	"GatewayService/internal/outbox"
+	"GatewayService/internal/rabbit"
 	"GatewayService/internal/service"
+	"errors"
 	"net/http"
 )
 
 type ErrorMapper struct {
-	mapper ErrorMap
+	mapper   ErrorMap
+	fallback ErrorInfo
 }
 
 func NewAuthErrorMapper() ErrorMapper {
 	authErrMap := NewAuthErrMap()
-	mapper := ErrorMapper{mapper: authErrMap}
+	mapper := ErrorMapper{
+		mapper: authErrMap,
+		fallback: ErrorInfo{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "Internal server error",
+		},
+	}
+	return mapper
+}
+
+func NewStoresErrorMapper() ErrorMapper {
+	storesErrMap := NewStoresErrMap()
+	mapper := ErrorMapper{
+		mapper: storesErrMap,
+		fallback: ErrorInfo{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "Failed to publish a message",
+		},
+	}
 	return mapper
 }
 
@@ -27,16 +51,40 @@ func (m ErrorMapper) MapError(err error) ErrorInfo {
 		return value
 	}
 
-	inf := ErrorInfo{
-		StatusCode: http.StatusInternalServerError,
-		Message:    "Internal server error",
+	for target, value := range m.mapper {
+		if errors.Is(err, target) {
+			return value
+		}
 	}
-	return inf
+
+	return m.fallback
 }
 
 func NewAuthErrMap() ErrorMap {
 	return ErrorMap{
-		service.ErrUserNotFound:    {StatusCode: http.StatusBadRequest, Message: "User with provided login does not exist"},
-		service.ErrInvalidPassword: {StatusCode: http.StatusBadRequest, Message: "Wrong password provided"},
+		service.ErrUserNotFound:        {StatusCode: http.StatusBadRequest, Message: "User with provided login does not exist"},
+		service.ErrInvalidPassword:     {StatusCode: http.StatusBadRequest, Message: "Wrong password provided"},
+		service.ErrInvalidRefreshToken: {StatusCode: http.StatusUnauthorized, Message: "Invalid or expired refresh token"},
+		service.ErrRefreshTokenReused:  {StatusCode: http.StatusUnauthorized, Message: "Refresh token was already used, sign in again"},
+	}
+}
+
+func NewStoresErrMap() ErrorMap {
+	return ErrorMap{
+		rabbit.ErrPublishNacked:   {StatusCode: http.StatusServiceUnavailable, Message: "Message broker refused the message"},
+		rabbit.ErrPublishReturned: {StatusCode: http.StatusBadGateway, Message: "Message broker could not route the message"},
+		rabbit.ErrConfirmTimeout:  {StatusCode: http.StatusGatewayTimeout, Message: "Message broker did not confirm the message in time"},
+		rabbit.ErrConfirmLost:     {StatusCode: http.StatusGatewayTimeout, Message: "Message broker connection was lost before the message was confirmed"},
+		rabbit.ErrPublisherBusy:   {StatusCode: http.StatusServiceUnavailable, Message: "Gateway is too busy to publish the message, try again later"},
+		message.ErrEncoding:       {StatusCode: http.StatusInternalServerError, Message: "Failed to encode the message"},
+		outbox.ErrSpoolFull:       {StatusCode: http.StatusServiceUnavailable, Message: "Message broker is unavailable and the outbox is full, try again later"},
+		rabbit.ErrNoRoute:         {StatusCode: http.StatusInternalServerError, Message: "Gateway has no route for this action"},
+		rabbit.ErrNotConnected:    {StatusCode: http.StatusServiceUnavailable, Message: "Message broker is unavailable, try again later"},
+		bus.ErrUnavailable:        {StatusCode: http.StatusServiceUnavailable, Message: "Message broker is unavailable, try again later"},
+		bus.ErrClosed:             {StatusCode: http.StatusServiceUnavailable, Message: "Message broker is unavailable, try again later"},
+		bus.ErrNoReply:            {StatusCode: http.StatusGatewayTimeout, Message: "Storage service did not reply in time"},
+		bus.ErrNoSubscribers:      {StatusCode: http.StatusBadGateway, Message: "Storage service is not listening for this action"},
+		bus.ErrNotFound:           {StatusCode: http.StatusNotFound, Message: "Store or store version not found"},
+		bus.ErrStorage:            {StatusCode: http.StatusBadGateway, Message: "Storage service failed to process the request"},
 	}
 }
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/operation"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type OperationReader interface {
+	Get(id, login string) (operation.Operation, error)
+}
+
+type OperationsHandler struct {
+	operations OperationReader
+	logger     *zap.Logger
+}
+
+func NewOperationsHandler(operations OperationReader, logger *zap.Logger) *OperationsHandler {
+	return &OperationsHandler{
+		operations: operations,
+		logger:     logger,
+	}
+}
+
+func (h *OperationsHandler) GetOperation(c *gin.Context) {
+	login := c.GetString("login")
+
+	op, err := h.operations.Get(c.Param("id"), login)
+	if err != nil {
+		if errors.Is(err, operation.ErrNotFound) {
+			c.JSON(http.StatusNotFound, response.BuildJSONResponse("Error", "Operation not found"))
+			return
+		}
+
+		h.logger.With(
+			zap.String("place", "OperationsHandler"),
+			zap.Error(err),
+		).Error("Failed to get operation")
+		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", "Internal server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", op))
+}
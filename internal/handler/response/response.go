@@ -1,8 +1,17 @@
 package response
 
 type JSONResult struct {
-	Message string      `json:"message"`
-	Body    interface{} `json:"body"`
+	Message    string      `json:"message"`
+	Body       interface{} `json:"body"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes the page of a cursor paginated list
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Count      int    `json:"count"`
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 func BuildJSONResponse(msg string, data interface{}) JSONResult {
@@ -11,3 +20,11 @@ func BuildJSONResponse(msg string, data interface{}) JSONResult {
 		Body:    data,
 	}
 }
+
+func BuildPaginatedJSONResponse(msg string, data interface{}, pagination Pagination) JSONResult {
+	return JSONResult{
+		Message:    msg,
+		Body:       data,
+		Pagination: &pagination,
+	}
+}
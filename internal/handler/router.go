@@ -2,27 +2,60 @@ package handler
 
 import (
 	"GatewayService/internal/middleware"
+	"expvar"
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(authHandler *AuthHandler, storesHandler *StoresHandler, middleware *middleware.Middleware) *gin.Engine {
+func NewRouter(authHandler *AuthHandler, storesHandler *StoresHandler, operationsHandler *OperationsHandler, eventsHandler *EventsHandler, graphqlHandler *GraphQLHandler, adminHandler *AdminHandler, authMiddleware *middleware.Middleware, idempotencyStore middleware.IdempotencyStore, brokerState middleware.BrokerState, outboxEnabled bool, callbackSecret string) *gin.Engine {
 	router := gin.Default()
 
 	authGroup := router.Group("auth")
 	authGroup.POST("/login", authHandler.SingIn)
+	authGroup.POST("/refresh", authHandler.Refresh)
+	authGroup.POST("/logout", authMiddleware.AccessTokenValidation(), authHandler.Logout)
+	authGroup.POST("/revoke", authMiddleware.AccessTokenValidation(), authMiddleware.AdminOnly(), authHandler.Revoke)
+
+	brokerAvailable := middleware.BrokerAvailability(brokerState)
+
+	writesAvailable := brokerAvailable
+	if outboxEnabled {
+		// the outbox spools mutations while the broker is away
+		writesAvailable = func(c *gin.Context) { c.Next() }
+	}
+
+	idempotent := middleware.Idempotency(idempotencyStore)
 
 	storesGroup := router.Group("storage")
-	storesGroup.POST("/store", middleware.AccessTokenValidation(), storesHandler.CreateStore)
-	storesGroup.POST("/store/:id/version", middleware.AccessTokenValidation(), storesHandler.CreateStoreVersion)
-	storesGroup.DELETE("/store/:id", middleware.AccessTokenValidation(), storesHandler.DeleteStore)
-	storesGroup.DELETE("/store/:id/version/:versionId", middleware.AccessTokenValidation(), storesHandler.DeleteStoreVersion)
-	storesGroup.GET("/store/:id", middleware.AccessTokenValidation(), storesHandler.GetStore)
-	storesGroup.GET("/store/:id/history", middleware.AccessTokenValidation(), storesHandler.GetStoreHistory)
-	storesGroup.GET("/store/:id/version/:versionId", middleware.AccessTokenValidation(), storesHandler.GetStoreVersion)
+	storesGroup.POST("/store", authMiddleware.AccessTokenValidation(), idempotent, writesAvailable, storesHandler.CreateStore)
+	storesGroup.POST("/store/:id/version", authMiddleware.AccessTokenValidation(), idempotent, writesAvailable, storesHandler.CreateStoreVersion)
+	storesGroup.POST("/stores:action", authMiddleware.AccessTokenValidation(), writesAvailable, storesHandler.StoresAction)
+	storesGroup.PATCH("/store/:id", authMiddleware.AccessTokenValidation(), idempotent, writesAvailable, storesHandler.UpdateStore)
+	storesGroup.DELETE("/store/:id", authMiddleware.AccessTokenValidation(), idempotent, writesAvailable, storesHandler.DeleteStore)
+	storesGroup.POST("/store/:id/version/:versionId/restore", authMiddleware.AccessTokenValidation(), idempotent, brokerAvailable, storesHandler.RestoreStoreVersion)
+	storesGroup.DELETE("/store/:id/version/:versionId", authMiddleware.AccessTokenValidation(), idempotent, writesAvailable, storesHandler.DeleteStoreVersion)
+	storesGroup.GET("/stores", authMiddleware.AccessTokenValidation(), brokerAvailable, storesHandler.ListStores)
+	storesGroup.GET("/store/:id", authMiddleware.AccessTokenValidation(), brokerAvailable, storesHandler.GetStore)
+	storesGroup.GET("/store/:id/history", authMiddleware.AccessTokenValidation(), brokerAvailable, storesHandler.GetStoreHistory)
+	storesGroup.GET("/store/:id/versions/compare", authMiddleware.AccessTokenValidation(), brokerAvailable, storesHandler.CompareStoreVersions)
+	storesGroup.GET("/store/:id/version/:versionId", authMiddleware.AccessTokenValidation(), brokerAvailable, storesHandler.GetStoreVersion)
+	storesGroup.GET("/events", middleware.QueryToken(), authMiddleware.AccessTokenValidation(), eventsHandler.Stream)
+	storesGroup.GET("/events/ws", middleware.QueryToken(), authMiddleware.AccessTokenValidation(), eventsHandler.WebSocket)
+
+	router.GET("/graphql", authMiddleware.AccessTokenValidation(), graphqlHandler.Query)
+	router.POST("/graphql", authMiddleware.AccessTokenValidation(), graphqlHandler.Query)
+
+	operationsGroup := router.Group("operations")
+	operationsGroup.GET("/:id", authMiddleware.AccessTokenValidation(), operationsHandler.GetOperation)
+
+	adminGroup := router.Group("admin")
+	adminGroup.GET("/outbox", authMiddleware.AccessTokenValidation(), authMiddleware.AdminOnly(), adminHandler.OutboxStats)
 
 	//for response handling from storage service
 	responseGroup := router.Group("response")
-	responseGroup.POST("/", storesHandler.HandleResponse)
+	responseGroup.POST("/", middleware.CallbackSecret(callbackSecret), storesHandler.HandleResponse)
+
+	//runtime and publisher metrics, expvar also exposes the command line and memstats
+	router.GET("/debug/vars", authMiddleware.AccessTokenValidation(), authMiddleware.AdminOnly(), gin.WrapH(expvar.Handler()))
 
 	return router
 }
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/message"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	FieldAdded   = "added"
+	FieldRemoved = "removed"
+	FieldChanged = "changed"
+
+	compareFormatJSONPatch = "jsonpatch"
+
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+var errVersionNotFound = errors.New("store version not found")
+
+type VersionCompareQuery struct {
+	From   string `form:"from" binding:"required"`
+	To     string `form:"to" binding:"required"`
+	Format string `form:"format" binding:"omitempty,oneof=diff jsonpatch"`
+}
+
+// FieldChange is the difference of one field between two versions
+type FieldChange struct {
+	Field    string      `json:"field"`
+	Kind     string      `json:"kind"`
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+type VersionDiff struct {
+	StoreID string        `json:"storeId"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// PatchOperation is an RFC 6902 operation
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// CompareStoreVersions fetches two versions of a store and returns what
+// changed from one to the other, as a field diff or as a JSON Patch
+func (h *StoresHandler) CompareStoreVersions(c *gin.Context) {
+	var query VersionCompareQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	storeId := c.Param("id")
+
+	var from, to storedVersion
+
+	g, ctx := errgroup.WithContext(c.Request.Context())
+	g.Go(func() (err error) {
+		from, err = h.storeVersion(ctx, c.GetString("login"), storeId, query.From)
+		return err
+	})
+	g.Go(func() (err error) {
+		to, err = h.storeVersion(ctx, c.GetString("login"), storeId, query.To)
+		return err
+	})
+
+	if err := g.Wait(); err != nil {
+		switch {
+		case errors.Is(err, errVersionNotFound):
+			c.JSON(http.StatusNotFound, response.BuildJSONResponse("Error", err.Error()))
+		case errors.Is(err, errUnexpectedStore):
+			c.JSON(http.StatusBadGateway, response.BuildJSONResponse("Error", err.Error()))
+		default:
+			h.logger.With(
+				zap.String("place", "Handler"),
+				zap.String("storeId", storeId),
+				zap.Error(err),
+			).Error("Failed to fetch the store versions to compare")
+			errInf := h.errorMapper.MapError(err)
+			c.JSON(errInf.StatusCode, response.BuildJSONResponse("Error", errInf.Message))
+		}
+		return
+	}
+
+	changes := diffVersions(from.fields, to.fields)
+
+	if query.Format == compareFormatJSONPatch {
+		c.Render(http.StatusOK, jsonPatchRender{operations: jsonPatch(changes)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", VersionDiff{
+		StoreID: storeId,
+		From:    query.From,
+		To:      query.To,
+		Changes: changes,
+	}))
+}
+
+// storedVersion is a version as the storage service returns it. fields keeps
+// every field of the reply, including the ones the gateway does not know yet
+type storedVersion struct {
+	StoreVersion
+	Deleted bool `json:"deleted"`
+
+	fields map[string]json.RawMessage
+}
+
+// versionMetadata are the fields of a version reply that describe the version
+// rather than the store, they are left out of a comparison
+var versionMetadata = map[string]bool{
+	"id":      true,
+	"deleted": true,
+}
+
+// storeVersion reads a version through get_store_version
+func (h *StoresHandler) storeVersion(ctx context.Context, login, storeId, versionId string) (storedVersion, error) {
+	body, err := h.stores.Request(ctx, login, message.GetStoreVersion, storeId, versionId, nil)
+	if errors.Is(err, bus.ErrNotFound) {
+		return storedVersion{}, fmt.Errorf("%w: %s", errVersionNotFound, versionId)
+	}
+	if err != nil {
+		return storedVersion{}, err
+	}
+
+	var version storedVersion
+	if err := json.Unmarshal(body, &version); err != nil {
+		return storedVersion{}, errUnexpectedStore
+	}
+	if err := json.Unmarshal(body, &version.fields); err != nil {
+		return storedVersion{}, errUnexpectedStore
+	}
+
+	return version, nil
+}
+
+// diffVersions compares every field of the two version replies by its JSON
+// value, in field order. A field missing or null on one side is added or removed
+func diffVersions(from, to map[string]json.RawMessage) []FieldChange {
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+
+	for _, name := range names {
+		if versionMetadata[name] {
+			continue
+		}
+
+		oldValue, newValue := from[name], to[name]
+		if sameJSON(oldValue, newValue) {
+			continue
+		}
+
+		change := FieldChange{Field: name}
+		switch {
+		case isAbsent(oldValue):
+			change.Kind = FieldAdded
+			change.NewValue = newValue
+		case isAbsent(newValue):
+			change.Kind = FieldRemoved
+			change.OldValue = oldValue
+		default:
+			change.Kind = FieldChanged
+			change.OldValue = oldValue
+			change.NewValue = newValue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// sameJSON compares two JSON values regardless of their formatting
+func sameJSON(a, b json.RawMessage) bool {
+	if isAbsent(a) || isAbsent(b) {
+		return isAbsent(a) && isAbsent(b)
+	}
+
+	var first, second interface{}
+	if json.Unmarshal(a, &first) != nil || json.Unmarshal(b, &second) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return reflect.DeepEqual(first, second)
+}
+
+func isAbsent(value json.RawMessage) bool {
+	return len(value) == 0 || bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
+
+// jsonPointerEscaper escapes a field name as an RFC 6901 reference token
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func jsonPatch(changes []FieldChange) []PatchOperation {
+	operations := make([]PatchOperation, 0, len(changes))
+
+	for _, change := range changes {
+		operation := PatchOperation{Path: "/" + jsonPointerEscaper.Replace(change.Field), Value: change.NewValue}
+		switch change.Kind {
+		case FieldAdded:
+			operation.Op = "add"
+		case FieldRemoved:
+			operation.Op = "remove"
+		default:
+			operation.Op = "replace"
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return operations
+}
+
+// jsonPatchRender writes a JSON Patch document with its own media type
+type jsonPatchRender struct {
+	operations []PatchOperation
+}
+
+func (r jsonPatchRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.operations)
+}
+
+func (r jsonPatchRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", JSONPatchContentType)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDiffVersionsComparesEveryField(t *testing.T) {
+	from := map[string]json.RawMessage{
+		"id":          json.RawMessage(`"v1"`),
+		"ownerName":   json.RawMessage(`"Doe, John"`),
+		"openingTime": json.RawMessage(`"2024-01-01 08:00:00"`),
+		"closingTime": json.RawMessage(`"2024-01-01 20:00:00"`),
+		"tags":        json.RawMessage(`["bakery"]`),
+		"note":        json.RawMessage(`"closed on Sundays"`),
+	}
+	to := map[string]json.RawMessage{
+		"id":          json.RawMessage(`"v2"`),
+		"ownerName":   json.RawMessage(`"Roe, Jane"`),
+		"openingTime": json.RawMessage(`"2024-01-01 08:00:00"`),
+		"closingTime": json.RawMessage(`"2024-01-01 20:00:00"`),
+		"tags":        json.RawMessage(`[ "bakery" ]`),
+		"note":        json.RawMessage(`null`),
+		"phone":       json.RawMessage(`"+49 30 1234"`),
+	}
+
+	changes := diffVersions(from, to)
+
+	want := []struct {
+		field string
+		kind  string
+	}{
+		{"note", FieldRemoved},
+		{"ownerName", FieldChanged},
+		{"phone", FieldAdded},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %+v, want %v", changes, want)
+	}
+	for i, w := range want {
+		if changes[i].Field != w.field || changes[i].Kind != w.kind {
+			t.Errorf("change %d is %+v, want %s %s", i, changes[i], w.field, w.kind)
+		}
+	}
+
+	patch, err := json.Marshal(jsonPatch(changes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPatch := `[{"op":"remove","path":"/note"},{"op":"replace","path":"/ownerName","value":"Roe, Jane"},{"op":"add","path":"/phone","value":"+49 30 1234"}]`
+	if string(patch) != wantPatch {
+		t.Errorf("patch is %s, want %s", patch, wantPatch)
+	}
+}
+
+func TestCompareStoreVersions(t *testing.T) {
+	f := newStoresFixture(t)
+	f.router.GET("/store/:id/versions/compare", f.handler.CompareStoreVersions)
+
+	id := f.createStore(t, "Corner Shop")
+	f.mutate(t, http.MethodPost, "/store/"+id+"/version", "application/json", StoreVersion{
+		OwnerName:   "Roe, Jane",
+		OpeningTime: "2024-01-01 08:00:00",
+		ClosingTime: "2024-01-01 18:00:00",
+	})
+
+	_, body := f.do(t, http.MethodGet, "/store/"+id+"/history", "", nil)
+
+	var history []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &history); err != nil || len(history) != 2 {
+		t.Fatalf("history is %s", body)
+	}
+
+	code, body := f.do(t, http.MethodGet, "/store/"+id+"/versions/compare?from="+history[0].ID+"&to="+history[1].ID, "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("compare: status %d, body %s", code, body)
+	}
+
+	var diff VersionDiff
+	if err := json.Unmarshal(body, &diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changes) != 2 || diff.Changes[0].Field != "closingTime" || diff.Changes[1].Field != "ownerName" {
+		t.Errorf("changes are %+v", diff.Changes)
+	}
+
+	code, _ = f.do(t, http.MethodGet, "/store/"+id+"/versions/compare?from="+history[0].ID+"&to=unknown", "", nil)
+	if code != http.StatusNotFound {
+		t.Errorf("compare with an unknown version: status %d, want 404", code)
+	}
+}
@@ -1,23 +1,55 @@
 package handler
 
 import (
+	"GatewayService/internal/handler/mapper"
 	"GatewayService/internal/handler/response"
 	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/message"
+	"GatewayService/internal/middleware"
+	"GatewayService/internal/operation"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/streadway/amqp"
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
+// StoresService sends the store actions to the storage service
+type StoresService interface {
+	StartOperation(ctx context.Context, login string, action message.Action, storeId, versionId string, data interface{}, idempotencyKey string) (operation.Operation, error)
+	Request(ctx context.Context, login string, action message.Action, storeId, versionId string, data interface{}) ([]byte, error)
+}
+
+// OperationCompleter records the results the storage service reports for operations
+type OperationCompleter interface {
+	Complete(id string, result json.RawMessage, errMessage string) (operation.Operation, error)
+}
+
+// StoreInvalidator drops the cached replies about a store
+type StoreInvalidator interface {
+	InvalidateStore(ctx context.Context, storeId string)
+}
+
+// EventPublisher pushes operation results to the live streams of a user
+type EventPublisher interface {
+	Publish(login, eventType string, data interface{}) error
+}
+
 type StoresHandler struct {
 	logger          *zap.Logger
-	rabbitMQChannel *amqp.Channel
-	rabbitMQConn    *amqp.Connection
-	rabbitMQQueue   string
+	stores          StoresService
+	cacheMaxAge     time.Duration
+	cache           StoreInvalidator
+	operations      OperationCompleter
+	events          EventPublisher
 	structValidator *validator.Validate
+	errorMapper     mapper.ErrorMapper
 }
 
 // Some custom validators used
@@ -35,21 +67,19 @@ type StoreVersion struct {
 	ClosingTime string `json:"closingTime" validate:"required,timeFormat"`
 }
 
-func NewStoresHandler(channel *amqp.Channel, rabbitMQConn *amqp.Connection, rabbitMQQueue string, logger *zap.Logger, structValidator *validator.Validate) *StoresHandler {
+func NewStoresHandler(stores StoresService, cacheMaxAge time.Duration, cache StoreInvalidator, operations OperationCompleter, events EventPublisher, logger *zap.Logger, structValidator *validator.Validate, errorMapper mapper.ErrorMapper) *StoresHandler {
 	return &StoresHandler{
 		logger:          logger,
-		rabbitMQChannel: channel,
-		rabbitMQConn:    rabbitMQConn,
-		rabbitMQQueue:   rabbitMQQueue,
+		stores:          stores,
+		cacheMaxAge:     cacheMaxAge,
+		cache:           cache,
+		operations:      operations,
+		events:          events,
 		structValidator: structValidator,
+		errorMapper:     errorMapper,
 	}
 }
 
-const (
-	messageForError   = "Failed to publish a message"
-	messageForSuccess = "Storage service is processing your message. Check status through logs"
-)
-
 func (h *StoresHandler) CreateStore(c *gin.Context) {
 	var store Store
 	if err := c.ShouldBindJSON(&store); err != nil {
@@ -62,22 +92,7 @@ func (h *StoresHandler) CreateStore(c *gin.Context) {
 		return
 	}
 
-	action := "create_store"
-
-	login := c.GetString("login")
-
-	err := h.sendMessage(buildMessage(store, action, login, "", ""))
-
-	if err != nil {
-		h.logger.With(
-			zap.String("place", "Handler"),
-			zap.Error(err),
-		).Error("Failed to publish a message")
-		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", messageForError))
-		return
-	}
-
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", messageForSuccess))
+	h.publishOperation(c, message.CreateStore, "", "", store)
 }
 
 func (h *StoresHandler) CreateStoreVersion(c *gin.Context) {
@@ -92,180 +107,172 @@ func (h *StoresHandler) CreateStoreVersion(c *gin.Context) {
 		return
 	}
 
-	action := "create_store_version"
-
-	login := c.GetString("login")
-
 	storeId := c.Param("id")
 
-	err := h.sendMessage(buildMessage(storeVersion, action, login, storeId, ""))
-
-	if err != nil {
-		h.logger.With(
-			zap.String("place", "Handler"),
-			zap.Error(err),
-		).Error("Failed to publish a message")
-		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", messageForError))
-		return
-	}
-
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", messageForSuccess))
+	h.publishOperation(c, message.CreateStoreVersion, storeId, "", storeVersion)
 }
 
 func (h *StoresHandler) DeleteStore(c *gin.Context) {
-	action := "delete_store"
+	storeId := c.Param("id")
 
-	login := c.GetString("login")
+	h.publishOperation(c, message.DeleteStore, storeId, "", nil)
+}
 
+func (h *StoresHandler) DeleteStoreVersion(c *gin.Context) {
 	storeId := c.Param("id")
 
-	err := h.sendMessage(buildMessage(nil, action, login, storeId, ""))
+	versionId := c.Param("versionId")
 
-	if err != nil {
-		h.logger.With(
-			zap.String("place", "Handler"),
-			zap.Error(err),
-		).Error("Failed to publish a message")
-		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", messageForError))
-		return
-	}
+	h.publishOperation(c, message.DeleteStoreVersion, storeId, versionId, nil)
+}
+
+func (h *StoresHandler) GetStore(c *gin.Context) {
+	storeId := c.Param("id")
 
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", messageForSuccess))
+	h.requestStorage(c, message.GetStore, storeId, "")
 }
 
-func (h *StoresHandler) DeleteStoreVersion(c *gin.Context) {
-	action := "delete_store_version"
+func (h *StoresHandler) GetStoreHistory(c *gin.Context) {
+	storeId := c.Param("id")
 
-	login := c.GetString("login")
+	h.requestStorage(c, message.GetStoreHistory, storeId, "")
+}
 
+func (h *StoresHandler) GetStoreVersion(c *gin.Context) {
 	storeId := c.Param("id")
 
 	versionId := c.Param("versionId")
 
-	err := h.sendMessage(buildMessage(nil, action, login, storeId, versionId))
+	h.requestStorage(c, message.GetStoreVersion, storeId, versionId)
+}
 
+// publishOperation publishes a mutating action as a new operation and answers
+// 202 with the operation the client can follow
+func (h *StoresHandler) publishOperation(c *gin.Context, action message.Action, storeId, versionId string, data interface{}) {
+	op, err := h.stores.StartOperation(c.Request.Context(), c.GetString("login"), action, storeId, versionId, data, c.GetString(middleware.IdempotencyKey))
 	if err != nil {
-		h.logger.With(
-			zap.String("place", "Handler"),
-			zap.Error(err),
-		).Error("Failed to publish a message")
-		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", messageForError))
+		errInf := h.errorMapper.MapError(err)
+		c.JSON(errInf.StatusCode, response.BuildJSONResponse("Error", errInf.Message))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", messageForSuccess))
+	c.JSON(http.StatusAccepted, response.BuildJSONResponse("Accepted", op))
 }
 
-func (h *StoresHandler) GetStore(c *gin.Context) {
-	action := "get_store"
-
+// requestStorage publishes a read action and writes the storage service reply
+// to the client, or 504 if it does not arrive before the reply timeout
+func (h *StoresHandler) requestStorage(c *gin.Context, action message.Action, storeId, versionId string) {
 	login := c.GetString("login")
 
-	storeId := c.Param("id")
-
-	err := h.sendMessage(buildMessage(nil, action, login, storeId, ""))
-
+	body, err := h.stores.Request(c.Request.Context(), login, action, storeId, versionId, nil)
 	if err != nil {
-		h.logger.With(
-			zap.String("place", "Handler"),
-			zap.Error(err),
-		).Error("Failed to publish a message")
-		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", messageForError))
+		errInf := h.errorMapper.MapError(err)
+		c.JSON(errInf.StatusCode, response.BuildJSONResponse("Error", errInf.Message))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", messageForSuccess))
-}
-
-func (h *StoresHandler) GetStoreHistory(c *gin.Context) {
-
-	action := "get_store_history"
-
-	login := c.GetString("login")
-
-	storeId := c.Param("id")
+	etag := replyETag(body)
 
-	err := h.sendMessage(buildMessage(nil, action, login, storeId, ""))
+	c.Header("ETag", etag)
+	if h.cacheMaxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(h.cacheMaxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
 
-	if err != nil {
-		h.logger.With(
-			zap.String("place", "Handler"),
-			zap.Error(err),
-		).Error("Failed to publish a message")
-		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", messageForError))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
 		return
 	}
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", messageForSuccess))
+
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", replyBody(body)))
 }
 
-func (h *StoresHandler) GetStoreVersion(c *gin.Context) {
+// replyETag is a strong validator of a storage service reply
+func replyETag(body []byte) string {
+	sum := sha256.Sum256(body)
 
-	action := "get_store_version"
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
 
-	login := c.GetString("login")
+// replyBody keeps JSON replies as they are and wraps anything else as a string
+func replyBody(body []byte) interface{} {
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
 
-	storeId := c.Param("id")
+	return string(body)
+}
 
-	versionId := c.Param("versionId")
+const operationEvent = "operation"
 
-	err := h.sendMessage(buildMessage(nil, action, login, storeId, versionId))
-	if err != nil {
-		h.logger.With(
-			zap.String("place", "Handler"),
-			zap.Error(err),
-		).Error("Failed to publish a message")
-		c.JSON(http.StatusInternalServerError, response.BuildJSONResponse("Error", messageForError))
-		return
-	}
-	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", messageForSuccess))
+// OperationResult is what the storage service posts once it has processed a mutating action
+type OperationResult struct {
+	OperationID string          `json:"operationId" binding:"required"`
+	Error       string          `json:"error"`
+	Data        json.RawMessage `json:"data"`
 }
 
 func (h *StoresHandler) HandleResponse(c *gin.Context) {
-	var payload interface{}
+	var result OperationResult
 
 	h.logger.Info("Trying to extract payload from response from storage service")
 
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := c.ShouldBindJSON(&result); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	fmt.Printf("Received payload: %+v\n", payload)
+	op, err := h.CompleteOperation(result)
+	switch {
+	case errors.Is(err, operation.ErrNotFound):
+		c.JSON(http.StatusNotFound, response.BuildJSONResponse("Error", err.Error()))
+		return
+	case errors.Is(err, operation.ErrCompleted):
+		c.JSON(http.StatusConflict, response.BuildJSONResponse("Error", err.Error()))
+		return
+	}
 
-	c.JSON(http.StatusOK, payload)
+	c.JSON(http.StatusOK, response.BuildJSONResponse("Success", op))
 }
 
-func (h *StoresHandler) sendMessage(message []byte) error {
-	err := h.rabbitMQChannel.Publish(
-		"",
-		h.rabbitMQQueue,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        message,
-		},
-	)
-	if err != nil {
-		return err
+// CompleteOperation records the result of an operation, drops the cached
+// replies about its store and pushes it to the live streams of its user.
+// The in-memory storage reports its results here
+func (h *StoresHandler) CompleteOperation(result OperationResult) (operation.Operation, error) {
+	op, err := h.operations.Complete(result.OperationID, result.Data, result.Error)
+	switch {
+	case errors.Is(err, operation.ErrNotFound):
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.String("operationId", result.OperationID),
+		).Warn("Received result for unknown operation")
+		return operation.Operation{}, err
+	case errors.Is(err, operation.ErrCompleted):
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.String("operationId", result.OperationID),
+		).Warn("Received another result for a completed operation")
+		return operation.Operation{}, err
 	}
 
-	return nil
-}
+	h.logger.With(
+		zap.String("operationId", op.ID),
+		zap.String("status", string(op.Status)),
+	).Info("Operation completed")
 
-func buildMessage(data interface{}, action, login, storeId, versionId string) []byte {
-	message := map[string]interface{}{
-		"storeId":   storeId,
-		"versionId": versionId,
-		"data":      data,
-		"action":    action,
-		"userLogin": login,
+	// reads between publish and apply may have cached the store as it was
+	if h.cache != nil && op.StoreID != "" {
+		h.cache.InvalidateStore(context.Background(), op.StoreID)
 	}
 
-	body, err := json.Marshal(message)
-	if err != nil {
-		return nil
+	if err := h.events.Publish(op.Login, operationEvent, op); err != nil {
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.String("operationId", op.ID),
+			zap.Error(err),
+		).Error("Failed to publish operation event")
 	}
 
-	return body
+	return op, nil
 }
@@ -0,0 +1,409 @@
+package handler
+
+import (
+	"GatewayService/internal/bus/memory"
+	"GatewayService/internal/events"
+	"GatewayService/internal/handler/mapper"
+	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/message"
+	"GatewayService/internal/middleware"
+	"GatewayService/internal/operation"
+	"GatewayService/internal/service"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLogin = "tester"
+
+type storesFixture struct {
+	router     *gin.Engine
+	handler    *StoresHandler
+	operations *operation.Tracker
+}
+
+// newStoresFixture serves the store endpoints from the in-memory bus and storage
+func newStoresFixture(t *testing.T) storesFixture {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	structValidator := validator.New()
+	if err := validation.RegisterCustomValidators(structValidator); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := zap.NewNop()
+	memoryBus := memory.NewBus(logger)
+	operations := operation.NewTracker(time.Minute)
+
+	stores := service.NewStoresService(memoryBus, time.Second, operations, logger)
+	h := NewStoresHandler(stores, 0, nil, operations, events.NewHub(10, time.Minute, 4), logger, structValidator, mapper.NewStoresErrorMapper())
+
+	storage := memory.NewStorage(func(operationId string, data json.RawMessage, errMessage string) {
+		_, _ = h.CompleteOperation(OperationResult{OperationID: operationId, Error: errMessage, Data: data})
+	}, logger)
+	if err := storage.Register(memoryBus); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("login", testLogin)
+	})
+	router.POST("/store", h.CreateStore)
+	router.POST("/store/:id/version", h.CreateStoreVersion)
+	router.PATCH("/store/:id", h.UpdateStore)
+	router.DELETE("/store/:id", h.DeleteStore)
+	router.POST("/stores:action", h.StoresAction)
+	router.GET("/stores", h.ListStores)
+	router.GET("/store/:id", h.GetStore)
+	router.GET("/store/:id/history", h.GetStoreHistory)
+
+	return storesFixture{router: router, handler: h, operations: operations}
+}
+
+func (f storesFixture) do(t *testing.T, method, path, contentType string, body interface{}) (int, json.RawMessage) {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &payload)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+
+	var reply struct {
+		Body json.RawMessage `json:"body"`
+	}
+	if rec.Code != http.StatusNotModified {
+		if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+			t.Fatalf("%s %s: unreadable reply %q", method, path, rec.Body.String())
+		}
+	}
+
+	return rec.Code, reply.Body
+}
+
+// mutate sends a mutating request and waits for its operation to finish
+func (f storesFixture) mutate(t *testing.T, method, path, contentType string, body interface{}) operation.Operation {
+	t.Helper()
+
+	code, reply := f.do(t, method, path, contentType, body)
+	if code != http.StatusAccepted {
+		t.Fatalf("%s %s: status %d, body %s", method, path, code, reply)
+	}
+
+	var op operation.Operation
+	if err := json.Unmarshal(reply, &op); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		current, err := f.operations.Get(op.ID, testLogin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Status != operation.Pending {
+			return current
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("%s %s: operation %s never completed", method, path, op.ID)
+	return operation.Operation{}
+}
+
+func (f storesFixture) createStore(t *testing.T, name string) string {
+	t.Helper()
+
+	op := f.mutate(t, http.MethodPost, "/store", "application/json", Store{
+		Name:        name,
+		Address:     "Germany, Berlin, Main Street 1",
+		OwnerName:   "Doe, John",
+		OpeningTime: "2024-01-01 08:00:00",
+		ClosingTime: "2024-01-01 20:00:00",
+	})
+	if op.Status != operation.Succeeded {
+		t.Fatalf("create failed: %s", op.Error)
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(op.Result, &created); err != nil || created.ID == "" {
+		t.Fatalf("create returned no store ID: %s", op.Result)
+	}
+
+	return created.ID
+}
+
+func TestStoresHandlerCreateAndGet(t *testing.T) {
+	f := newStoresFixture(t)
+
+	id := f.createStore(t, "Corner Shop")
+
+	code, body := f.do(t, http.MethodGet, "/store/"+id, "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("get: status %d, body %s", code, body)
+	}
+
+	var store Store
+	if err := json.Unmarshal(body, &store); err != nil {
+		t.Fatal(err)
+	}
+	if store.Name != "Corner Shop" || store.OwnerName != "Doe, John" {
+		t.Errorf("get returned %+v", store)
+	}
+}
+
+func TestStoresHandlerUpdateAndVersions(t *testing.T) {
+	f := newStoresFixture(t)
+
+	id := f.createStore(t, "Corner Shop")
+
+	op := f.mutate(t, http.MethodPatch, "/store/"+id, MergePatchContentType, map[string]interface{}{
+		"name": "Corner Store",
+	})
+	if op.Status != operation.Succeeded {
+		t.Fatalf("update failed: %s", op.Error)
+	}
+
+	op = f.mutate(t, http.MethodPost, "/store/"+id+"/version", "application/json", StoreVersion{
+		OwnerName:   "Roe, Jane",
+		OpeningTime: "2024-02-01 09:00:00",
+		ClosingTime: "2024-02-01 18:00:00",
+	})
+	if op.Status != operation.Succeeded {
+		t.Fatalf("new version failed: %s", op.Error)
+	}
+
+	_, body := f.do(t, http.MethodGet, "/store/"+id, "", nil)
+
+	var store Store
+	if err := json.Unmarshal(body, &store); err != nil {
+		t.Fatal(err)
+	}
+	if store.Name != "Corner Store" || store.OwnerName != "Roe, Jane" {
+		t.Errorf("store after update and new version is %+v", store)
+	}
+
+	_, body = f.do(t, http.MethodGet, "/store/"+id+"/history", "", nil)
+
+	var history []storedVersion
+	if err := json.Unmarshal(body, &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("history has %d versions, want 2", len(history))
+	}
+}
+
+func TestStoresHandlerList(t *testing.T) {
+	f := newStoresFixture(t)
+
+	for _, name := range []string{"Corner Shop", "Bakery", "Cornflakes"} {
+		f.createStore(t, name)
+	}
+
+	code, body := f.do(t, http.MethodGet, "/stores?namePrefix=Corn&limit=1", "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list: status %d, body %s", code, body)
+	}
+
+	var page []Store
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].Name != "Corner Shop" {
+		t.Errorf("first page is %+v", page)
+	}
+}
+
+func TestStoresHandlerMissingStore(t *testing.T) {
+	f := newStoresFixture(t)
+
+	code, _ := f.do(t, http.MethodPatch, "/store/unknown", MergePatchContentType, map[string]interface{}{
+		"name": "Corner Store",
+	})
+	if code != http.StatusNotFound {
+		t.Errorf("patch of an unknown store: status %d, want 404", code)
+	}
+
+	if code, _ := f.do(t, http.MethodGet, "/store/unknown", "", nil); code != http.StatusNotFound {
+		t.Errorf("get of an unknown store: status %d, want 404", code)
+	}
+
+	op := f.mutate(t, http.MethodDelete, "/store/unknown", "", nil)
+	if op.Status != operation.Failed {
+		t.Errorf("delete of an unknown store: status %s, want failed", op.Status)
+	}
+}
+
+func TestStoresHandlerPatchNeedsMergePatch(t *testing.T) {
+	f := newStoresFixture(t)
+
+	id := f.createStore(t, "Corner Shop")
+
+	code, _ := f.do(t, http.MethodPatch, "/store/"+id, "application/json", map[string]interface{}{
+		"name": "Corner Store",
+	})
+	if code != http.StatusUnsupportedMediaType {
+		t.Errorf("patch as application/json: status %d, want 415", code)
+	}
+}
+
+type invalidationRecorder struct {
+	stores []string
+}
+
+func (r *invalidationRecorder) InvalidateStore(_ context.Context, storeId string) {
+	r.stores = append(r.stores, storeId)
+}
+
+func TestCompleteOperationInvalidatesStore(t *testing.T) {
+	operations := operation.NewTracker(time.Minute)
+	invalidations := &invalidationRecorder{}
+
+	h := NewStoresHandler(nil, 0, invalidations, operations, events.NewHub(10, time.Minute, 4), zap.NewNop(), validator.New(), mapper.NewStoresErrorMapper())
+
+	op := operations.Start(testLogin, "update_store", "store-1", "")
+
+	if _, err := h.CompleteOperation(OperationResult{OperationID: op.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(invalidations.stores) != 1 || invalidations.stores[0] != "store-1" {
+		t.Errorf("invalidated %v, want [store-1]", invalidations.stores)
+	}
+}
+
+// importStores posts an import and returns the report lines
+func (f storesFixture) importStores(t *testing.T, contentType, idempotencyKey, body string) (int, []json.RawMessage) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/stores:import", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if idempotencyKey != "" {
+		req.Header.Set(middleware.IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+
+	var lines []json.RawMessage
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		lines = append(lines, json.RawMessage(append([]byte(nil), scanner.Bytes()...)))
+	}
+
+	return rec.Code, lines
+}
+
+func TestImportStoresStreamsReport(t *testing.T) {
+	f := newStoresFixture(t)
+
+	body := `{"name":"Corner Shop","address":"Germany, Berlin, Main Street 1","ownerName":"Doe, John","openingTime":"2024-01-01 08:00:00","closingTime":"2024-01-01 20:00:00"}
+{"name":
+{"name":"Bakery","address":"Germany, Berlin, Main Street 2","ownerName":"Roe, Jane","openingTime":"2024-01-01 06:00:00","closingTime":"2024-01-01 18:00:00"}
+`
+
+	code, lines := f.importStores(t, "application/x-ndjson", "", body)
+	if code != http.StatusOK {
+		t.Fatalf("import: status %d, report %s", code, lines)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("report has %d lines, want 3 rows and the summary: %s", len(lines), lines)
+	}
+
+	want := []struct {
+		line   int
+		status string
+	}{{1, rowAccepted}, {2, rowRejected}, {3, rowAccepted}}
+	for i, w := range want {
+		var row ImportRow
+		if err := json.Unmarshal(lines[i], &row); err != nil {
+			t.Fatal(err)
+		}
+		if row.Line != w.line || row.Status != w.status {
+			t.Errorf("row %d is %+v, want line %d %s", i, row, w.line, w.status)
+		}
+	}
+
+	var summary ImportSummary
+	if err := json.Unmarshal(lines[3], &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Accepted != 2 || summary.Rejected != 1 {
+		t.Errorf("summary is %+v", summary)
+	}
+}
+
+func TestImportStoresRejectsIncompleteCSVHeader(t *testing.T) {
+	f := newStoresFixture(t)
+
+	code, _ := f.importStores(t, "text/csv", "", "name,address\nCorner Shop,Main Street 1\n")
+	if code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", code)
+	}
+}
+
+// keyRecorder keeps the idempotency keys of the started operations
+type keyRecorder struct {
+	keys []string
+}
+
+func (r *keyRecorder) StartOperation(_ context.Context, _ string, _ message.Action, _, _ string, _ interface{}, idempotencyKey string) (operation.Operation, error) {
+	r.keys = append(r.keys, idempotencyKey)
+	return operation.Operation{ID: "op"}, nil
+}
+
+func (r *keyRecorder) Request(context.Context, string, message.Action, string, string, interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+func TestImportStoresDerivesRowKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	structValidator := validator.New()
+	if err := validation.RegisterCustomValidators(structValidator); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := &keyRecorder{}
+	h := NewStoresHandler(stores, 0, nil, operation.NewTracker(time.Minute), events.NewHub(10, time.Minute, 4), zap.NewNop(), structValidator, mapper.NewStoresErrorMapper())
+
+	router := gin.New()
+	router.POST("/stores:action", h.StoresAction)
+	f := storesFixture{router: router}
+
+	body := "name,address,ownerName,openingTime,closingTime\n" +
+		"Corner Shop,\"Germany, Berlin, Main Street 1\",\"Doe, John\",2024-01-01 08:00:00,2024-01-01 20:00:00\n" +
+		"Bakery,\"Germany, Berlin, Main Street 2\",\"Roe, Jane\",2024-01-01 06:00:00,2024-01-01 18:00:00\n"
+
+	if code, lines := f.importStores(t, "text/csv", "import-1", body); code != http.StatusOK {
+		t.Fatalf("import: status %d, report %s", code, lines)
+	}
+
+	if len(stores.keys) != 2 || stores.keys[0] != "import-1:2" || stores.keys[1] != "import-1:3" {
+		t.Errorf("row keys are %q, want [import-1:2 import-1:3]", stores.keys)
+	}
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/message"
+	"GatewayService/internal/middleware"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	importAction = ":import"
+
+	// longest NDJSON line accepted, a store is a few hundred bytes
+	maxImportLineSize = 64 * 1024
+
+	rowAccepted = "accepted"
+	rowRejected = "rejected"
+)
+
+// ImportRow reports what happened to a single line of an import
+type ImportRow struct {
+	Line        int         `json:"line"`
+	Status      string      `json:"status"`
+	OperationID string      `json:"operationId,omitempty"`
+	Errors      interface{} `json:"errors,omitempty"`
+}
+
+// ImportSummary is the last line of an import report
+type ImportSummary struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+}
+
+// StoresAction dispatches the custom methods of the stores collection.
+// Gin cannot route a literal colon, so "/stores:import" arrives here with
+// the method as the action param
+func (h *StoresHandler) StoresAction(c *gin.Context) {
+	switch c.Param("action") {
+	case importAction:
+		h.ImportStores(c)
+	default:
+		c.JSON(http.StatusNotFound, response.BuildJSONResponse("Error", "Unknown stores method"))
+	}
+}
+
+// ImportStores creates a store for every valid NDJSON line or CSV record of
+// the body. The body is parsed as it is read and every valid row is published
+// as its own operation. The report is streamed back as NDJSON, an ImportRow
+// per line of the body followed by the ImportSummary.
+// The body is not buffered for the idempotency middleware, instead every row
+// carries a key derived from the Idempotency-Key of the import and its line
+func (h *StoresHandler) ImportStores(c *gin.Context) {
+	importKey := c.GetHeader(middleware.IdempotencyKeyHeader)
+	if len(importKey) > middleware.MaxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest,
+			response.BuildJSONResponse("Error", "Idempotency-Key must not be longer than 255 characters"))
+		return
+	}
+
+	// a large import outlives the server read and write timeouts, starting
+	// with the CSV header read below
+	controller := http.NewResponseController(c.Writer)
+	if err := controller.SetReadDeadline(time.Time{}); err != nil {
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.Error(err),
+		).Warn("Failed to clear read deadline for store import")
+	}
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.Error(err),
+		).Warn("Failed to clear write deadline for store import")
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+	var rows storeReader
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		rows = newNDJSONStoreReader(c.Request.Body)
+	case "text/csv":
+		reader, err := newCSVStoreReader(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.BuildJSONResponse("Error", err.Error()))
+			return
+		}
+		rows = reader
+	default:
+		c.JSON(http.StatusUnsupportedMediaType,
+			response.BuildJSONResponse("Error", "Import expects application/x-ndjson or text/csv"))
+		return
+	}
+
+	login := c.GetString("login")
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	write := func(line interface{}) {
+		if err := encoder.Encode(line); err != nil {
+			h.logger.With(
+				zap.String("place", "Handler"),
+				zap.Error(err),
+			).Warn("Failed to write store import report")
+		}
+		c.Writer.Flush()
+	}
+
+	var summary ImportSummary
+	reject := func(line int, errs interface{}) {
+		summary.Rejected++
+		write(ImportRow{Line: line, Status: rowRejected, Errors: errs})
+	}
+
+	for {
+		line, store, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			reject(line, rowErr.Error())
+			continue
+		}
+		if err != nil {
+			// the rest of the body cannot be read, report what was done so far
+			reject(line, err.Error())
+			break
+		}
+
+		if err := h.structValidator.Struct(store); err != nil {
+			reject(line, validation.FormatValidatorError(err).Body)
+			continue
+		}
+
+		var rowKey string
+		if importKey != "" {
+			rowKey = importKey + ":" + strconv.Itoa(line)
+		}
+
+		op, err := h.stores.StartOperation(c.Request.Context(), login, message.CreateStore, "", "", store, rowKey)
+		if err != nil {
+			reject(line, h.errorMapper.MapError(err).Message)
+			continue
+		}
+
+		summary.Accepted++
+		write(ImportRow{Line: line, Status: rowAccepted, OperationID: op.ID})
+	}
+
+	write(summary)
+}
+
+// storeReader yields the stores of an import one by one with their line
+// number, io.EOF once the body is consumed
+type storeReader interface {
+	Next() (int, Store, error)
+}
+
+// rowError is a malformed row, the following ones can still be read
+type rowError struct {
+	err error
+}
+
+func (e *rowError) Error() string {
+	return e.err.Error()
+}
+
+type ndjsonStoreReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONStoreReader(body io.Reader) *ndjsonStoreReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLineSize)
+
+	return &ndjsonStoreReader{scanner: scanner}
+}
+
+func (r *ndjsonStoreReader) Next() (int, Store, error) {
+	for r.scanner.Scan() {
+		r.line++
+
+		raw := bytes.TrimSpace(r.scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var store Store
+		if err := json.Unmarshal(raw, &store); err != nil {
+			return r.line, Store{}, &rowError{err: fmt.Errorf("invalid JSON: %v", err)}
+		}
+
+		return r.line, store, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line is longer than %d bytes", maxImportLineSize)
+		}
+		return r.line + 1, Store{}, err
+	}
+
+	return r.line, Store{}, io.EOF
+}
+
+// csvStoreReader reads records under a header row naming the Store JSON
+// fields, e.g. "name,address,ownerName,openingTime,closingTime", in any order
+type csvStoreReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	fields  int
+}
+
+var storeColumns = []string{"name", "address", "ownerName", "openingTime", "closingTime"}
+
+func newCSVStoreReader(body io.Reader) (*csvStoreReader, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	var missing []string
+	for _, name := range storeColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV header misses columns: %s", strings.Join(missing, ", "))
+	}
+
+	return &csvStoreReader{reader: reader, columns: columns, fields: len(header)}, nil
+}
+
+func (r *csvStoreReader) Next() (int, Store, error) {
+	record, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, Store{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, Store{}, &rowError{err: parseErr.Err}
+	}
+	if err != nil {
+		return 0, Store{}, err
+	}
+
+	line, _ := r.reader.FieldPos(0)
+
+	if len(record) != r.fields {
+		return line, Store{}, &rowError{err: fmt.Errorf("expected %d fields, got %d", r.fields, len(record))}
+	}
+
+	field := func(name string) string {
+		return record[r.columns[name]]
+	}
+
+	return line, Store{
+		Name:        field("name"),
+		Address:     field("address"),
+		OwnerName:   field("ownerName"),
+		OpeningTime: field("openingTime"),
+		ClosingTime: field("closingTime"),
+	}, nil
+}
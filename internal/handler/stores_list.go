@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/message"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+const defaultListLimit = 20
+
+// StoreListQuery is the query string of GET /storage/stores. Sort names a
+// store field, prefixed with "-" for descending order
+type StoreListQuery struct {
+	OwnerName  string `form:"owner" json:"ownerName,omitempty" validate:"omitempty,max=80"`
+	NamePrefix string `form:"namePrefix" json:"namePrefix,omitempty" validate:"omitempty,max=40"`
+	City       string `form:"city" json:"city,omitempty" validate:"omitempty,max=80"`
+	OpenAt     string `form:"openAt" json:"openAt,omitempty" validate:"omitempty,timeFormat"`
+	Sort       string `form:"sort" json:"sort,omitempty" validate:"omitempty,oneof=name -name ownerName -ownerName openingTime -openingTime closingTime -closingTime"`
+	Limit      int    `form:"limit" json:"limit" validate:"min=0,max=100"`
+	Cursor     string `form:"cursor" json:"cursor,omitempty" validate:"omitempty,max=512"`
+}
+
+// storeListReply is the list_stores reply of the storage service
+type storeListReply struct {
+	Stores     []json.RawMessage `json:"stores"`
+	NextCursor string            `json:"nextCursor"`
+}
+
+// ListStores searches the stores and returns a page of them. The pagination
+// metadata and the Link header carry the cursor of the following page
+func (h *StoresHandler) ListStores(c *gin.Context) {
+	var query StoreListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	if err := h.structValidator.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+
+	body, err := h.stores.Request(c.Request.Context(), c.GetString("login"), message.ListStores, "", "", query)
+	if err == nil {
+		h.writeStorePage(c, query, body)
+		return
+	}
+
+	h.logger.With(
+		zap.String("place", "Handler"),
+		zap.String("action", string(message.ListStores)),
+		zap.Error(err),
+	).Error("Failed to list stores")
+	errInf := h.errorMapper.MapError(err)
+	c.JSON(errInf.StatusCode, response.BuildJSONResponse("Error", errInf.Message))
+}
+
+func (h *StoresHandler) writeStorePage(c *gin.Context, query StoreListQuery, body []byte) {
+	var reply storeListReply
+	if err := json.Unmarshal(body, &reply); err != nil {
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.Error(err),
+		).Error("Storage service sent an unreadable store list")
+		c.JSON(http.StatusBadGateway, response.BuildJSONResponse("Error", "Storage service returned an unreadable store list"))
+		return
+	}
+
+	if reply.Stores == nil {
+		reply.Stores = []json.RawMessage{}
+	}
+
+	var links []string
+	if reply.NextCursor != "" {
+		links = append(links, pageLink(c, reply.NextCursor, "next"))
+	}
+	if query.Cursor != "" {
+		links = append(links, pageLink(c, "", "first"))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+
+	c.JSON(http.StatusOK, response.BuildPaginatedJSONResponse("Success", reply.Stores, response.Pagination{
+		Limit:      query.Limit,
+		Count:      len(reply.Stores),
+		Cursor:     query.Cursor,
+		NextCursor: reply.NextCursor,
+	}))
+}
+
+// pageLink is an RFC 8288 link to the current request with another cursor
+func pageLink(c *gin.Context, cursor, rel string) string {
+	target := *c.Request.URL
+
+	values := target.Query()
+	if cursor == "" {
+		values.Del("cursor")
+	} else {
+		values.Set("cursor", cursor)
+	}
+	target.RawQuery = values.Encode()
+
+	return fmt.Sprintf("<%s>; rel=%q", target.RequestURI(), rel)
+}
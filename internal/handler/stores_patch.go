@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/handler/validation"
+	"GatewayService/internal/message"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const MergePatchContentType = "application/merge-patch+json"
+
+// UpdateStore applies an RFC 7396 merge patch to the current store, validates
+// the result like a new store and publishes update_store with the fields the
+// patch actually changes
+func (h *StoresHandler) UpdateStore(c *gin.Context) {
+	// a plain JSON body is refused, it would be read as a full replacement
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if mediaType != MergePatchContentType {
+		c.JSON(http.StatusUnsupportedMediaType,
+			response.BuildJSONResponse("Error", "Update expects "+MergePatchContentType))
+		return
+	}
+
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.BuildJSONResponse("Error", "Failed to read request body"))
+		return
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		c.JSON(http.StatusBadRequest, response.BuildJSONResponse("Error", "Patch must be a JSON object"))
+		return
+	}
+	if len(patch) == 0 {
+		c.JSON(http.StatusBadRequest, response.BuildJSONResponse("Error", "Patch is empty"))
+		return
+	}
+
+	fields := storeFields()
+	for name := range patch {
+		if _, ok := fields[name]; !ok {
+			c.JSON(http.StatusBadRequest, response.BuildJSONResponse("Error", "Unknown store field "+name))
+			return
+		}
+	}
+
+	storeId := c.Param("id")
+
+	current, err := h.currentStore(c, storeId)
+	switch {
+	case errors.Is(err, errStoreNotFound):
+		c.JSON(http.StatusNotFound, response.BuildJSONResponse("Error", err.Error()))
+		return
+	case errors.Is(err, errUnexpectedStore):
+		c.JSON(http.StatusBadGateway, response.BuildJSONResponse("Error", err.Error()))
+		return
+	case err != nil:
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.String("storeId", storeId),
+			zap.Error(err),
+		).Error("Failed to fetch the store to patch")
+		errInf := h.errorMapper.MapError(err)
+		c.JSON(errInf.StatusCode, response.BuildJSONResponse("Error", errInf.Message))
+		return
+	}
+
+	merged, err := applyMergePatch(current, patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	if err := h.structValidator.Struct(merged); err != nil {
+		c.JSON(http.StatusBadRequest, validation.FormatValidatorError(err))
+		return
+	}
+
+	changed := changedFields(current, merged)
+	if len(changed) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, response.BuildJSONResponse("Error", "Patch does not change the store"))
+		return
+	}
+
+	h.publishOperation(c, message.UpdateStore, storeId, "", changed)
+}
+
+var (
+	errStoreNotFound   = errors.New("store not found")
+	errUnexpectedStore = errors.New("storage service returned a store the gateway cannot read")
+)
+
+// currentStore reads the store through get_store, as a GET would
+func (h *StoresHandler) currentStore(c *gin.Context, storeId string) (Store, error) {
+	body, err := h.stores.Request(c.Request.Context(), c.GetString("login"), message.GetStore, storeId, "", nil)
+	if errors.Is(err, bus.ErrNotFound) {
+		return Store{}, errStoreNotFound
+	}
+	if err != nil {
+		return Store{}, err
+	}
+
+	var store Store
+	if err := json.Unmarshal(body, &store); err != nil {
+		return Store{}, errUnexpectedStore
+	}
+
+	return store, nil
+}
+
+// applyMergePatch merges patch into store as RFC 7396 describes. Store has
+// no nested objects, so a null removes the field and any other value replaces it
+func applyMergePatch(store Store, patch map[string]interface{}) (Store, error) {
+	raw, err := json.Marshal(store)
+	if err != nil {
+		return Store{}, err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return Store{}, err
+	}
+
+	for name, value := range patch {
+		if value == nil {
+			delete(document, name)
+		} else {
+			document[name] = value
+		}
+	}
+
+	merged, err := json.Marshal(document)
+	if err != nil {
+		return Store{}, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+
+	var result Store
+	if err := decoder.Decode(&result); err != nil {
+		return Store{}, err
+	}
+
+	return result, nil
+}
+
+// changedFields maps the JSON name of every field differing between
+// before and after to its new value
+func changedFields(before, after Store) map[string]interface{} {
+	changed := make(map[string]interface{})
+
+	beforeValue := reflect.ValueOf(before)
+	afterValue := reflect.ValueOf(after)
+
+	for i := 0; i < beforeValue.NumField(); i++ {
+		if beforeValue.Field(i).Interface() != afterValue.Field(i).Interface() {
+			changed[jsonName(beforeValue.Type().Field(i))] = afterValue.Field(i).Interface()
+		}
+	}
+
+	return changed
+}
+
+func storeFields() map[string]struct{} {
+	storeType := reflect.TypeOf(Store{})
+
+	fields := make(map[string]struct{}, storeType.NumField())
+	for i := 0; i < storeType.NumField(); i++ {
+		fields[jsonName(storeType.Field(i))] = struct{}{}
+	}
+
+	return fields
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
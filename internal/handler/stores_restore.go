@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/message"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// StoreVersionRestore is the payload of restore_store_version: a copy of the
+// restored version and where it comes from
+type StoreVersionRestore struct {
+	StoreVersion
+	RestoredBy   string `json:"restoredBy"`
+	RestoredFrom string `json:"restoredFrom"`
+}
+
+// RestoreStoreVersion publishes restore_store_version, which makes a copy of
+// an earlier version the newest one. Deleted versions cannot be restored
+func (h *StoresHandler) RestoreStoreVersion(c *gin.Context) {
+	storeId := c.Param("id")
+
+	versionId := c.Param("versionId")
+
+	login := c.GetString("login")
+
+	version, err := h.storeVersion(c.Request.Context(), login, storeId, versionId)
+	switch {
+	case errors.Is(err, errVersionNotFound):
+		c.JSON(http.StatusNotFound, response.BuildJSONResponse("Error", err.Error()))
+		return
+	case errors.Is(err, errUnexpectedStore):
+		c.JSON(http.StatusBadGateway, response.BuildJSONResponse("Error", err.Error()))
+		return
+	case err != nil:
+		h.logger.With(
+			zap.String("place", "Handler"),
+			zap.String("storeId", storeId),
+			zap.String("versionId", versionId),
+			zap.Error(err),
+		).Error("Failed to fetch the store version to restore")
+		errInf := h.errorMapper.MapError(err)
+		c.JSON(errInf.StatusCode, response.BuildJSONResponse("Error", errInf.Message))
+		return
+	}
+
+	if version.Deleted {
+		c.JSON(http.StatusConflict, response.BuildJSONResponse("Error", "Deleted store versions cannot be restored"))
+		return
+	}
+
+	h.publishOperation(c, message.RestoreStoreVersion, storeId, versionId, StoreVersionRestore{
+		StoreVersion: version.StoreVersion,
+		RestoredBy:   login,
+		RestoredFrom: versionId,
+	})
+}
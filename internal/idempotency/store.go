@@ -0,0 +1,116 @@
+package idempotency
+
+import (
+	"GatewayService/internal/cleanup"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInFlight = errors.New("a request with this idempotency key is still being processed")
+	ErrMismatch = errors.New("idempotency key was already used with a different request")
+)
+
+// Response is what the first request with a key answered, replayed as is on retries
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type entry struct {
+	requestHash string
+	done        bool
+	response    Response
+	expiresAt   time.Time
+}
+
+// Store remembers the requests made with an idempotency key for a window.
+// Keys are scoped per login, so two users never share one
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	window  time.Duration
+}
+
+func NewStore(window time.Duration) *Store {
+	return &Store{
+		entries: make(map[string]*entry),
+		window:  window,
+	}
+}
+
+// Begin reserves key for a request. It returns the stored response when
+// the same request already completed, ErrInFlight when it is still being
+// processed and ErrMismatch when the key came with another request
+func (s *Store) Begin(login, key, requestHash string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	id := scopedKey(login, key)
+
+	if e, ok := s.entries[id]; ok && now.Before(e.expiresAt) {
+		switch {
+		case e.requestHash != requestHash:
+			return nil, ErrMismatch
+		case !e.done:
+			return nil, ErrInFlight
+		default:
+			response := e.response
+			return &response, nil
+		}
+	}
+
+	s.entries[id] = &entry{
+		requestHash: requestHash,
+		expiresAt:   now.Add(s.window),
+	}
+
+	return nil, nil
+}
+
+// Finish stores the response of the request holding key
+func (s *Store) Finish(login, key string, response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[scopedKey(login, key)]
+	if !ok {
+		return
+	}
+
+	e.done = true
+	e.response = response
+	e.expiresAt = time.Now().Add(s.window)
+}
+
+// Abort frees key so the request can be retried, e.g. after a server error
+func (s *Store) Abort(login, key string) {
+	s.mu.Lock()
+	delete(s.entries, scopedKey(login, key))
+	s.mu.Unlock()
+}
+
+// Run evicts expired keys every interval until ctx is done
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	cleanup.Run(ctx, interval, s.evictExpired)
+}
+
+func (s *Store) evictExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, id)
+		}
+	}
+}
+
+func scopedKey(login, key string) string {
+	return login + "\x00" + key
+}
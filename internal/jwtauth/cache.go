@@ -0,0 +1,146 @@
+package jwtauth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/sync/singleflight"
+	"sync"
+	"time"
+)
+
+// Provider validates access tokens, it is implemented by the Verifier
+// and by the remote auth generator client
+type Provider interface {
+	ValidateToken(token string) (*Claims, error)
+}
+
+type cacheEntry struct {
+	key       string
+	claims    *Claims
+	err       error
+	expiresAt time.Time
+}
+
+// CachingProvider remembers the results of the next Provider by token hash.
+// A valid token is remembered until it expires but at most maxTTL, a failed
+// validation for negativeTTL only. At most maxEntries results are kept, the
+// least recently used one is evicted first
+type CachingProvider struct {
+	next        Provider
+	maxTTL      time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+
+	// concurrent validations of one token share a single call to next
+	group singleflight.Group
+}
+
+func NewCachingProvider(next Provider, maxTTL, negativeTTL time.Duration, maxEntries int) *CachingProvider {
+	return &CachingProvider{
+		next:        next,
+		maxTTL:      maxTTL,
+		negativeTTL: negativeTTL,
+		maxEntries:  maxEntries,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+}
+
+func (p *CachingProvider) ValidateToken(token string) (*Claims, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	if entry, ok := p.get(key); ok {
+		return copyClaims(entry.claims), entry.err
+	}
+
+	result, err, _ := p.group.Do(key, func() (interface{}, error) {
+		claims, err := p.next.ValidateToken(token)
+		p.set(key, claims, err)
+
+		return claims, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copyClaims(result.(*Claims)), nil
+}
+
+func (p *CachingProvider) get(key string) (*cacheEntry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		p.remove(element)
+		return nil, false
+	}
+
+	p.order.MoveToFront(element)
+
+	return entry, true
+}
+
+func (p *CachingProvider) set(key string, claims *Claims, err error) {
+	now := time.Now()
+
+	var expiresAt time.Time
+	if err != nil {
+		expiresAt = now.Add(p.negativeTTL)
+	} else {
+		expiresAt = now.Add(p.maxTTL)
+		if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(expiresAt) {
+			expiresAt = claims.ExpiresAt.Time
+		}
+	}
+
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.entries[key]; ok {
+		p.remove(element)
+	}
+
+	p.entries[key] = p.order.PushFront(&cacheEntry{
+		key:       key,
+		claims:    claims,
+		err:       err,
+		expiresAt: expiresAt,
+	})
+
+	for p.order.Len() > p.maxEntries {
+		p.remove(p.order.Back())
+	}
+}
+
+func (p *CachingProvider) remove(element *list.Element) {
+	p.order.Remove(element)
+	delete(p.entries, element.Value.(*cacheEntry).key)
+}
+
+// copyClaims keeps callers from changing the cached claims
+func copyClaims(claims *Claims) *Claims {
+	if claims == nil {
+		return nil
+	}
+
+	c := *claims
+	c.Audience = append([]string(nil), claims.Audience...)
+
+	return &c
+}
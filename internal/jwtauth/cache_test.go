@@ -0,0 +1,196 @@
+package jwtauth
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers with the claims of known tokens, rejects the others and counts
+// the validations. Validations wait for release when it is set
+type fakeProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+
+	mu     sync.Mutex
+	claims map[string]*Claims
+}
+
+func (p *fakeProvider) ValidateToken(token string) (*Claims, error) {
+	p.calls.Add(1)
+
+	if p.release != nil {
+		<-p.release
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	claims, ok := p.claims[token]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// claimsExpiringIn keeps the sub-second part jwt.NewNumericDate drops
+func claimsExpiringIn(d time.Duration) *Claims {
+	return &Claims{
+		Login:            "tester",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: &jwt.NumericDate{Time: time.Now().Add(d)}},
+	}
+}
+
+func TestCachingProviderTTL(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiresIn   time.Duration
+		maxTTL      time.Duration
+		negativeTTL time.Duration
+		cachedFor   time.Duration
+		expiredBy   time.Duration
+	}{
+		{name: "capped by maxTTL", expiresIn: time.Hour, maxTTL: 60 * time.Millisecond, cachedFor: 20 * time.Millisecond, expiredBy: 90 * time.Millisecond},
+		{name: "capped by exp", expiresIn: 60 * time.Millisecond, maxTTL: time.Hour, cachedFor: 20 * time.Millisecond, expiredBy: 90 * time.Millisecond},
+		{name: "negative", maxTTL: time.Hour, negativeTTL: 60 * time.Millisecond, cachedFor: 20 * time.Millisecond, expiredBy: 90 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		next := &fakeProvider{claims: map[string]*Claims{}}
+		// without an expiry the token is rejected
+		if tt.expiresIn > 0 {
+			next.claims["token"] = claimsExpiringIn(tt.expiresIn)
+		}
+		p := NewCachingProvider(next, tt.maxTTL, tt.negativeTTL, 10)
+
+		start := time.Now()
+		_, firstErr := p.ValidateToken("token")
+
+		time.Sleep(tt.cachedFor - time.Since(start))
+		if _, err := p.ValidateToken("token"); (err == nil) != (firstErr == nil) {
+			t.Errorf("%s: cached result changed to %v", tt.name, err)
+		}
+		if calls := next.calls.Load(); calls != 1 {
+			t.Errorf("%s: validated %d times within the TTL, want once", tt.name, calls)
+		}
+
+		time.Sleep(tt.expiredBy - time.Since(start))
+		_, _ = p.ValidateToken("token")
+		if calls := next.calls.Load(); calls != 2 {
+			t.Errorf("%s: validated %d times after the TTL, want twice", tt.name, calls)
+		}
+	}
+}
+
+func TestCachingProviderSkipsZeroNegativeTTL(t *testing.T) {
+	next := &fakeProvider{claims: map[string]*Claims{}}
+	p := NewCachingProvider(next, time.Hour, 0, 10)
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.ValidateToken("token"); err == nil {
+			t.Fatal("invalid token was accepted")
+		}
+	}
+
+	if calls := next.calls.Load(); calls != 2 {
+		t.Errorf("validated %d times, want every time", calls)
+	}
+}
+
+func TestCachingProviderMergesConcurrentLookups(t *testing.T) {
+	next := &fakeProvider{
+		claims:  map[string]*Claims{"token": claimsExpiringIn(time.Hour)},
+		release: make(chan struct{}),
+	}
+	p := NewCachingProvider(next, time.Hour, time.Minute, 10)
+
+	const lookups = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, lookups)
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.ValidateToken("token")
+			errs <- err
+		}()
+	}
+
+	// let every lookup reach the provider or the one in flight
+	deadline := time.Now().Add(time.Second)
+	for next.calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(next.release)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("validated %d times, want once", calls)
+	}
+}
+
+func TestCachingProviderEvictsLeastRecentlyUsed(t *testing.T) {
+	next := &fakeProvider{claims: map[string]*Claims{
+		"a": claimsExpiringIn(time.Hour),
+		"b": claimsExpiringIn(time.Hour),
+		"c": claimsExpiringIn(time.Hour),
+	}}
+	p := NewCachingProvider(next, time.Hour, time.Minute, 2)
+
+	for _, token := range []string{"a", "b", "a", "c"} {
+		if _, err := p.ValidateToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := next.calls.Load(); calls != 3 {
+		t.Fatalf("validated %d times, want 3", calls)
+	}
+
+	// b was the least recently used token when c was cached
+	_, _ = p.ValidateToken("a")
+	_, _ = p.ValidateToken("c")
+	if calls := next.calls.Load(); calls != 3 {
+		t.Errorf("a or c was evicted")
+	}
+
+	_, _ = p.ValidateToken("b")
+	if calls := next.calls.Load(); calls != 4 {
+		t.Errorf("b was not evicted")
+	}
+}
+
+func TestCachingProviderCopiesClaims(t *testing.T) {
+	claims := claimsExpiringIn(time.Hour)
+	claims.Audience = jwt.ClaimStrings{"gateway"}
+
+	next := &fakeProvider{claims: map[string]*Claims{"token": claims}}
+	p := NewCachingProvider(next, time.Hour, time.Minute, 10)
+
+	first, err := p.ValidateToken("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Login = "intruder"
+	first.Audience[0] = "billing"
+
+	second, err := p.ValidateToken("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Login != "tester" || second.Audience[0] != "gateway" {
+		t.Errorf("cached claims were changed through a result: %+v", second)
+	}
+}
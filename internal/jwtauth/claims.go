@@ -0,0 +1,43 @@
+// Package jwtauth verifies the access tokens of the gateway users locally,
+// without a round trip to the auth generator
+package jwtauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// LocalMode verifies signatures in the gateway, RemoteMode asks the
+	// auth generator /validate endpoint
+	LocalMode  = "local"
+	RemoteMode = "remote"
+)
+
+var (
+	ErrUnknownKey    = errors.New("token is signed with an unknown key")
+	ErrMissingLogin  = errors.New("invalid token payload")
+	ErrMissingExpiry = errors.New("token has no expiration time")
+	ErrWrongIssuer   = errors.New("token issuer is not accepted")
+	ErrWrongAudience = errors.New("token audience is not accepted")
+)
+
+// Claims are the claims of a gateway access token
+type Claims struct {
+	Login string `json:"login"`
+	jwt.RegisteredClaims
+}
+
+// TokenID identifies a token in revocations, by its jti or, when it has
+// none, by the hash of the token
+func TokenID(token string, claims *Claims) string {
+	if claims.ID != "" {
+		return claims.ID
+	}
+
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
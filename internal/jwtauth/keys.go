@@ -0,0 +1,243 @@
+package jwtauth
+
+import (
+	"GatewayService/internal/config"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// keySet holds the public keys tokens are verified with, by key ID.
+// Keys from a JWKS URL are fetched again when a token names an unknown key,
+// at most once per refresh interval
+type keySet struct {
+	mu sync.RWMutex
+	// static keys come from PEM files and a JWKS file, keys adds the JWKS URL ones
+	static  map[string]interface{}
+	keys    map[string]interface{}
+	fetched time.Time
+
+	jwksURL string
+	refresh time.Duration
+	client  *http.Client
+}
+
+func loadPublicKeys(files []config.PublicKeyConfig) (map[string]interface{}, error) {
+	keys := make(map[string]interface{}, len(files))
+
+	for _, file := range files {
+		if _, ok := keys[file.Kid]; ok {
+			return nil, fmt.Errorf("public key %s is configured twice", file.Kid)
+		}
+
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parsePEMPublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("public key %s: %w", file.Kid, err)
+		}
+
+		keys[file.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func parsePEMPublicKey(data []byte) (interface{}, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+
+	return jwt.ParseECPublicKeyFromPEM(data)
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+
+	for _, k := range set.Keys {
+		// encryption keys have nothing to do with token signatures
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey returns nil for key types tokens are never signed with
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (s *keySet) key(ctx context.Context, kid string) (interface{}, error) {
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+
+	if s.jwksURL == "" {
+		return nil, ErrUnknownKey
+	}
+
+	s.mu.Lock()
+	if time.Since(s.fetched) >= s.refresh {
+		if err := s.fetchLocked(ctx); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	s.mu.Unlock()
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// lookup accepts a token without a key ID when there is one key only
+func (s *keySet) lookup(kid string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[kid]
+
+	return key, ok
+}
+
+func (s *keySet) fetchLocked(ctx context.Context) error {
+	// a failed fetch waits out the refresh interval as well
+	s.fetched = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.jwksURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s with status code %d", s.jwksURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	// keys the provider dropped are dropped here as well
+	for kid, key := range s.static {
+		keys[kid] = key
+	}
+	s.keys = keys
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package jwtauth
+
+import (
+	"GatewayService/internal/config"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseJWKS(t *testing.T) {
+	offCurve := ecJWK("ec", &ecKey.PublicKey)
+	offCurve.Y = offCurve.X
+
+	unsupportedCurve := ecJWK("ec", &ecKey.PublicKey)
+	unsupportedCurve.Crv = "P-192"
+
+	encryption := rsaJWK("enc", &rsaKey.PublicKey)
+	encryption.Use = "enc"
+
+	tests := []struct {
+		name    string
+		keys    []jwk
+		wantErr bool
+		want    []string
+	}{
+		{name: "rsa and ec", keys: []jwk{rsaJWK("rsa", &rsaKey.PublicKey), ecJWK("ec", &ecKey.PublicKey)}, want: []string{"rsa", "ec"}},
+		{name: "encryption key skipped", keys: []jwk{encryption, rsaJWK("rsa", &rsaKey.PublicKey)}, want: []string{"rsa"}},
+		{name: "other key type skipped", keys: []jwk{{Kid: "oct", Kty: "oct"}}},
+		{name: "point off the curve", keys: []jwk{offCurve}, wantErr: true},
+		{name: "unsupported curve", keys: []jwk{unsupportedCurve}, wantErr: true},
+		{name: "malformed modulus", keys: []jwk{{Kid: "rsa", Kty: "RSA", N: "not base64!", E: "AQAB"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		keys, err := parseJWKS(mustJSON(t, jwks{Keys: tt.keys}))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if len(keys) != len(tt.want) {
+			t.Errorf("%s: got %d keys, want %v", tt.name, len(keys), tt.want)
+		}
+		for _, kid := range tt.want {
+			if _, ok := keys[kid]; !ok {
+				t.Errorf("%s: key %s is missing", tt.name, kid)
+			}
+		}
+	}
+
+	keys, err := parseJWKS(mustJSON(t, jwks{Keys: []jwk{rsaJWK("rsa", &rsaKey.PublicKey)}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !keys["rsa"].(*rsa.PublicKey).Equal(&rsaKey.PublicKey) {
+		t.Error("parsed RSA key differs from the encoded one")
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func writePEM(t *testing.T, key interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	rsaPath := writePEM(t, &rsaKey.PublicKey)
+	ecPath := writePEM(t, &ecKey.PublicKey)
+
+	keys, err := loadPublicKeys([]config.PublicKeyConfig{{Kid: "rsa", Path: rsaPath}, {Kid: "ec", Path: ecPath}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := keys["rsa"].(*rsa.PublicKey); !ok {
+		t.Errorf("rsa key is %T", keys["rsa"])
+	}
+	if _, ok := keys["ec"].(*ecdsa.PublicKey); !ok {
+		t.Errorf("ec key is %T", keys["ec"])
+	}
+
+	if _, err := loadPublicKeys([]config.PublicKeyConfig{{Kid: "rsa", Path: rsaPath}, {Kid: "rsa", Path: ecPath}}); err == nil {
+		t.Error("kid configured twice was accepted")
+	}
+}
@@ -0,0 +1,127 @@
+package jwtauth
+
+import (
+	"GatewayService/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Verifier checks the signature and the time, issuer and audience claims of
+// access tokens with the keys from the JWT config
+type Verifier struct {
+	secret   []byte
+	keys     *keySet
+	parser   *jwt.Parser
+	issuer   string
+	audience string
+}
+
+func NewVerifier(cfg config.JWTConfig, logger *zap.Logger) (*Verifier, error) {
+	if len(cfg.Algorithms) == 0 {
+		return nil, errors.New("no JWT signing algorithms configured")
+	}
+
+	v := &Verifier{
+		secret:   []byte(cfg.Secret),
+		parser:   jwt.NewParser(jwt.WithValidMethods(cfg.Algorithms)),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+
+	static, err := loadPublicKeys(cfg.PublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for kid, key := range keys {
+			static[kid] = key
+		}
+	}
+
+	v.keys = &keySet{
+		static:  static,
+		keys:    static,
+		jwksURL: cfg.JWKSURL,
+		refresh: cfg.JWKSRefresh,
+		client:  &http.Client{Timeout: cfg.JWKSTimeout},
+	}
+
+	for _, alg := range cfg.Algorithms {
+		if strings.HasPrefix(alg, "HS") && len(v.secret) == 0 {
+			return nil, fmt.Errorf("%s is accepted but no JWT secret is configured", alg)
+		}
+	}
+
+	if cfg.JWKSURL != "" {
+		v.keys.mu.Lock()
+		err := v.keys.fetchLocked(context.Background())
+		v.keys.mu.Unlock()
+
+		// tokens are verified with the static keys until the next fetch succeeds
+		if err != nil {
+			logger.With(
+				zap.String("place", "NewVerifier"),
+				zap.Error(err),
+			).Warn("Failed to fetch JWKS")
+		}
+	}
+
+	return v, nil
+}
+
+// ValidateToken returns the claims of a token with a valid signature that is
+// not expired, already valid and issued by and for the configured parties
+func (v *Verifier) ValidateToken(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := v.parser.ParseWithClaims(tokenStr, claims, v.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, ErrMissingExpiry
+	}
+
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return nil, ErrWrongIssuer
+	}
+
+	if v.audience != "" && !claims.VerifyAudience(v.audience, true) {
+		return nil, ErrWrongAudience
+	}
+
+	if claims.Login == "" {
+		return nil, ErrMissingLogin
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	// a key of the wrong type for the signing method fails verification
+	return v.keys.key(context.Background(), kid)
+}
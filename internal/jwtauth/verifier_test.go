@@ -0,0 +1,294 @@
+package jwtauth
+
+import (
+	"GatewayService/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "auth-generator"
+	testAudience = "gateway"
+	testSecret   = "hmac-secret"
+)
+
+var (
+	rsaKey   = mustRSAKey()
+	otherKey = mustRSAKey()
+	ecKey    = mustECKey()
+)
+
+func mustRSAKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+func mustECKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{Kid: kid, Kty: "RSA", Use: "sig", N: encodeBigInt(key.N), E: encodeBigInt(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) jwk {
+	return jwk{Kid: kid, Kty: "EC", Crv: "P-256", X: encodeBigInt(key.X), Y: encodeBigInt(key.Y)}
+}
+
+// jwksServer serves a key set that can be rotated and counts its fetches
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []jwk
+	fetches int
+}
+
+func newJWKSServer(t *testing.T, keys ...jwk) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.fetches++
+		_ = json.NewEncoder(w).Encode(jwks{Keys: s.keys})
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *jwksServer) rotate(keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = keys
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.fetches
+}
+
+func newTestVerifier(t *testing.T, algorithms []string, jwksURL string, refresh time.Duration) *Verifier {
+	t.Helper()
+
+	v, err := NewVerifier(config.JWTConfig{
+		Algorithms:  algorithms,
+		Secret:      testSecret,
+		JWKSURL:     jwksURL,
+		JWKSRefresh: refresh,
+		JWKSTimeout: time.Second,
+		Issuer:      testIssuer,
+		Audience:    testAudience,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func validClaims() *Claims {
+	return &Claims{
+		Login: "tester",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims *Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestVerifierValidateToken(t *testing.T) {
+	server := newJWKSServer(t, rsaJWK("rsa", &rsaKey.PublicKey), ecJWK("ec", &ecKey.PublicKey))
+	v := newTestVerifier(t, []string{"RS256", "ES256"}, server.URL, time.Hour)
+
+	tests := []struct {
+		name   string
+		token  func() string
+		want   error
+		reject bool
+	}{
+		{name: "valid RS256", token: func() string {
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", validClaims())
+		}},
+		{name: "valid ES256", token: func() string {
+			return sign(t, jwt.SigningMethodES256, ecKey, "ec", validClaims())
+		}},
+		{name: "algorithm not allowed", reject: true, token: func() string {
+			return sign(t, jwt.SigningMethodRS384, rsaKey, "rsa", validClaims())
+		}},
+		{name: "HS token against an RS config", reject: true, token: func() string {
+			return sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims())
+		}},
+		{name: "unsigned token", reject: true, token: func() string {
+			return sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims())
+		}},
+		{name: "wrong key", reject: true, token: func() string {
+			return sign(t, jwt.SigningMethodRS256, otherKey, "rsa", validClaims())
+		}},
+		{name: "kid of a key of another type", reject: true, token: func() string {
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "ec", validClaims())
+		}},
+		{name: "unknown kid", want: ErrUnknownKey, token: func() string {
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "unknown", validClaims())
+		}},
+		{name: "missing exp", want: ErrMissingExpiry, token: func() string {
+			claims := validClaims()
+			claims.ExpiresAt = nil
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims)
+		}},
+		{name: "expired", want: jwt.ErrTokenExpired, token: func() string {
+			claims := validClaims()
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims)
+		}},
+		{name: "not yet valid", want: jwt.ErrTokenNotValidYet, token: func() string {
+			claims := validClaims()
+			claims.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims)
+		}},
+		{name: "wrong issuer", want: ErrWrongIssuer, token: func() string {
+			claims := validClaims()
+			claims.Issuer = "somebody"
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims)
+		}},
+		{name: "wrong audience", want: ErrWrongAudience, token: func() string {
+			claims := validClaims()
+			claims.Audience = jwt.ClaimStrings{"billing"}
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims)
+		}},
+		{name: "missing login", want: ErrMissingLogin, token: func() string {
+			claims := validClaims()
+			claims.Login = ""
+			return sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims)
+		}},
+	}
+
+	for _, tt := range tests {
+		claims, err := v.ValidateToken(tt.token())
+
+		switch {
+		case tt.want != nil:
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+			}
+		case tt.reject:
+			if err == nil {
+				t.Errorf("%s: token was accepted", tt.name)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case claims.Login != "tester":
+			t.Errorf("%s: login %q", tt.name, claims.Login)
+		}
+	}
+}
+
+func TestVerifierAcceptsHSWithSecret(t *testing.T) {
+	v := newTestVerifier(t, []string{"HS256"}, "", time.Hour)
+
+	if _, err := v.ValidateToken(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims())); err != nil {
+		t.Errorf("valid HS256 token: %v", err)
+	}
+
+	if _, err := v.ValidateToken(sign(t, jwt.SigningMethodHS256, []byte("another secret"), "", validClaims())); err == nil {
+		t.Error("token signed with another secret was accepted")
+	}
+}
+
+func TestNewVerifierNeedsSecretForHS(t *testing.T) {
+	_, err := NewVerifier(config.JWTConfig{Algorithms: []string{"RS256", "HS256"}}, zap.NewNop())
+	if err == nil {
+		t.Error("HS256 was accepted without a secret")
+	}
+}
+
+func TestVerifierRefetchesJWKSOncePerInterval(t *testing.T) {
+	const refresh = 50 * time.Millisecond
+
+	server := newJWKSServer(t, rsaJWK("old", &rsaKey.PublicKey))
+	v := newTestVerifier(t, []string{"RS256"}, server.URL, refresh)
+
+	if got := server.fetchCount(); got != 1 {
+		t.Fatalf("fetched %d times at start, want once", got)
+	}
+
+	// the provider rotates to a key the gateway has not seen yet
+	server.rotate(rsaJWK("old", &rsaKey.PublicKey), rsaJWK("new", &otherKey.PublicKey))
+
+	rotated := sign(t, jwt.SigningMethodRS256, otherKey, "new", validClaims())
+	unknown := sign(t, jwt.SigningMethodRS256, rsaKey, "unknown", validClaims())
+
+	// within the interval unknown kids do not reach the provider
+	for i := 0; i < 3; i++ {
+		if _, err := v.ValidateToken(rotated); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("token with a kid not fetched yet: got %v, want %v", err, ErrUnknownKey)
+		}
+	}
+	if got := server.fetchCount(); got != 1 {
+		t.Errorf("fetched %d times within the interval, want once", got)
+	}
+
+	time.Sleep(refresh)
+
+	if _, err := v.ValidateToken(rotated); err != nil {
+		t.Fatalf("token signed with the rotated key: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := v.ValidateToken(unknown); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("unknown kid: got %v, want %v", err, ErrUnknownKey)
+		}
+	}
+	if got := server.fetchCount(); got != 2 {
+		t.Errorf("fetched %d times after the interval, want twice", got)
+	}
+}
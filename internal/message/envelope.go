@@ -0,0 +1,101 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// SchemaVersion is the version of the envelope described in api/message.schema.json.
+// Bump the major part on any change the storage service cannot ignore
+const SchemaVersion = "1.0"
+
+var ErrEncoding = errors.New("failed to encode message")
+
+type Action string
+
+const (
+	CreateStore         Action = "create_store"
+	CreateStoreVersion  Action = "create_store_version"
+	DeleteStore         Action = "delete_store"
+	DeleteStoreVersion  Action = "delete_store_version"
+	GetStore            Action = "get_store"
+	GetStoreHistory     Action = "get_store_history"
+	GetStoreVersion     Action = "get_store_version"
+	UpdateStore         Action = "update_store"
+	ListStores          Action = "list_stores"
+	RestoreStoreVersion Action = "restore_store_version"
+)
+
+// Envelope is the message the gateway sends to the storage service for every action
+type Envelope struct {
+	MessageID     string    `json:"messageId"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	SchemaVersion string    `json:"schemaVersion"`
+	Action        Action    `json:"action"`
+	IssuedAt      time.Time `json:"issuedAt"`
+	UserLogin     string    `json:"userLogin"`
+	StoreID       string    `json:"storeId,omitempty"`
+	VersionID     string    `json:"versionId,omitempty"`
+	OperationID   string    `json:"operationId,omitempty"`
+	// set when the client sent an Idempotency-Key, for the storage service to dedupe on
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+}
+
+func NewEnvelope(action Action, login, storeId, versionId string, payload interface{}) (Envelope, error) {
+	envelope := Envelope{
+		MessageID:     uuid.NewString(),
+		SchemaVersion: SchemaVersion,
+		Action:        action,
+		IssuedAt:      time.Now().UTC(),
+		UserLogin:     login,
+		StoreID:       storeId,
+		VersionID:     versionId,
+	}
+
+	if payload != nil {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("%w: payload of %s: %v", ErrEncoding, action, err)
+		}
+		envelope.Payload = body
+	}
+
+	return envelope, nil
+}
+
+func (e Envelope) Marshal() ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+
+	return body, nil
+}
+
+// Headers are copied into the transport headers so the storage service can
+// route and reject messages without decoding the body
+func (e Envelope) Headers() map[string]string {
+	headers := map[string]string{
+		"schema-version": e.SchemaVersion,
+		"user-login":     e.UserLogin,
+	}
+
+	if e.StoreID != "" {
+		headers["store-id"] = e.StoreID
+	}
+	if e.VersionID != "" {
+		headers["version-id"] = e.VersionID
+	}
+	if e.OperationID != "" {
+		headers["operation-id"] = e.OperationID
+	}
+	if e.IdempotencyKey != "" {
+		headers["idempotency-key"] = e.IdempotencyKey
+	}
+
+	return headers
+}
@@ -2,29 +2,56 @@ package middleware
 
 import (
 	"GatewayService/internal/handler/response"
+	"GatewayService/internal/jwtauth"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
 	Header = "Authorization"
+	// RFC 6750 query parameter of the access token
+	AccessTokenParam = "access_token"
+	// context keys of the verified claims and the ID of the access token
+	ClaimsKey  = "claims"
+	TokenIDKey = "tokenId"
 )
 
+var (
+	ErrTokenRevoked          = errors.New("token has been revoked")
+	errRevocationUnavailable = errors.New("token revocation list is unavailable")
+)
+
+// JWTProvider returns the claims of valid access tokens, either verified in
+// the gateway or by the auth generator
 type JWTProvider interface {
-	ValidateToken(token string) error
+	ValidateToken(token string) (*jwtauth.Claims, error)
+}
+
+// Denylist reports whether a token was revoked before it expired
+type Denylist interface {
+	Revoked(ctx context.Context, tokenId, login string, issuedAt time.Time) (bool, error)
 }
 
 type Middleware struct {
 	provider JWTProvider
+	denylist Denylist
+	admins   map[string]struct{}
 }
 
-func NewMiddleware(provider JWTProvider) *Middleware {
+func NewMiddleware(provider JWTProvider, denylist Denylist, adminLogins []string) *Middleware {
 	m := &Middleware{
 		provider: provider,
+		denylist: denylist,
+		admins:   make(map[string]struct{}, len(adminLogins)),
+	}
+
+	for _, login := range adminLogins {
+		m.admins[login] = struct{}{}
 	}
 
 	return m
@@ -40,19 +67,90 @@ func (m *Middleware) AccessTokenValidation() gin.HandlerFunc {
 			return
 		}
 
-		err = m.provider.ValidateToken(accessToken)
+		claims, tokenId, err := m.verify(c.Request.Context(), accessToken)
+		if errors.Is(err, errRevocationUnavailable) {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, response.BuildJSONResponse("Error", err.Error()))
+			return
+		}
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, response.BuildJSONResponse("Error", err.Error()))
 			return
 		}
 
-		login, err := ExtractLoginFromToken(accessToken)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, response.BuildJSONResponse("Error", err.Error()))
+		c.Set("login", claims.Login)
+		c.Set(ClaimsKey, claims)
+		c.Set(TokenIDKey, tokenId)
+		c.Next()
+	}
+}
+
+// Authenticate validates an access token and returns the login it was issued
+// to. Every transport of the gateway authenticates through it
+func (m *Middleware) Authenticate(accessToken string) (string, error) {
+	claims, _, err := m.verify(context.Background(), accessToken)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Login, nil
+}
+
+// verify validates the token and refuses it when it was revoked, validation
+// results may be cached but revocations are checked on every call
+func (m *Middleware) verify(ctx context.Context, accessToken string) (*jwtauth.Claims, string, error) {
+	claims, err := m.provider.ValidateToken(accessToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tokenId := jwtauth.TokenID(accessToken, claims)
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	revoked, err := m.denylist.Revoked(ctx, tokenId, claims.Login, issuedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", errRevocationUnavailable, err)
+	}
+	if revoked {
+		return nil, "", ErrTokenRevoked
+	}
+
+	return claims, tokenId, nil
+}
+
+// QueryToken lets clients that cannot set headers, like the browser
+// EventSource and WebSocket, pass the access token as the access_token query
+// parameter. It must run before AccessTokenValidation, the header wins when
+// both are given. Keep it to such routes, query strings end up in access logs
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+
+		if token := query.Get(AccessTokenParam); token != "" {
+			if c.GetHeader(Header) == "" {
+				c.Request.Header.Set(Header, "Bearer "+token)
+			}
+
+			// handlers never see the token in the URL
+			query.Del(AccessTokenParam)
+			c.Request.URL.RawQuery = query.Encode()
+		}
+
+		c.Next()
+	}
+}
+
+// AdminOnly must run after AccessTokenValidation
+func (m *Middleware) AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := m.admins[c.GetString("login")]; !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.BuildJSONResponse("Error", "admin access required"))
 			return
 		}
 
-		c.Set("login", login)
 		c.Next()
 	}
 }
@@ -69,22 +167,3 @@ func ExtractTokenFromHeader(c *gin.Context) (string, error) {
 
 	return parts[1], nil
 }
-
-func ExtractLoginFromToken(tokenStr string) (string, error) {
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenStr, jwt.MapClaims{})
-	if err != nil {
-		return "", err
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", fmt.Errorf("invalid token payload")
-	}
-
-	login, ok := claims["login"].(string)
-
-	if !ok {
-		return "", fmt.Errorf("invalid token payload")
-	}
-	return login, nil
-}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"GatewayService/internal/jwtauth"
+	"GatewayService/internal/revocation"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeProvider accepts the tokens it knows the claims of
+type fakeProvider map[string]*jwtauth.Claims
+
+func (p fakeProvider) ValidateToken(token string) (*jwtauth.Claims, error) {
+	claims, ok := p[token]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+type failingDenylist struct{}
+
+func (failingDenylist) Revoked(context.Context, string, string, time.Time) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func sessionClaims(jti, login string, issuedAt time.Time) *jwtauth.Claims {
+	return &jwtauth.Claims{
+		Login: login,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Hour)),
+		},
+	}
+}
+
+func newAuthRouter(m *Middleware) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/me", m.AccessTokenValidation(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("login")+" "+c.GetString(TokenIDKey))
+	})
+
+	return router
+}
+
+func authorize(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if token != "" {
+		req.Header.Set(Header, "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAccessTokenValidationRevocations(t *testing.T) {
+	issuedAt := time.Now().Add(-time.Minute)
+	provider := fakeProvider{
+		"laptop": sessionClaims("jti-laptop", "alice", issuedAt),
+		"phone":  sessionClaims("jti-phone", "alice", issuedAt),
+		"bob":    sessionClaims("jti-bob", "bob", issuedAt),
+	}
+
+	denylist := revocation.NewDenylist(revocation.NewMemory(), time.Hour)
+	router := newAuthRouter(NewMiddleware(provider, denylist, nil))
+	ctx := context.Background()
+
+	for token := range provider {
+		if rec := authorize(router, token); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d before any revocation", token, rec.Code)
+		}
+	}
+
+	// logging out of the laptop keeps the phone signed in
+	if err := denylist.RevokeToken(ctx, "jti-laptop", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for token, want := range map[string]int{"laptop": http.StatusUnauthorized, "phone": http.StatusOK, "bob": http.StatusOK} {
+		if rec := authorize(router, token); rec.Code != want {
+			t.Errorf("after logout, %s: status %d, want %d", token, rec.Code, want)
+		}
+	}
+
+	if err := denylist.RevokeLogin(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	for token, want := range map[string]int{"phone": http.StatusUnauthorized, "bob": http.StatusOK} {
+		if rec := authorize(router, token); rec.Code != want {
+			t.Errorf("after revoking alice, %s: status %d, want %d", token, rec.Code, want)
+		}
+	}
+}
+
+func TestAccessTokenValidationSetsTokenID(t *testing.T) {
+	provider := fakeProvider{"laptop": sessionClaims("jti-laptop", "alice", time.Now())}
+	router := newAuthRouter(NewMiddleware(provider, revocation.NewDenylist(revocation.NewMemory(), time.Hour), nil))
+
+	rec := authorize(router, "laptop")
+	if rec.Code != http.StatusOK || rec.Body.String() != "alice jti-laptop" {
+		t.Errorf("status %d, body %q", rec.Code, rec.Body)
+	}
+}
+
+func TestAccessTokenValidationFailures(t *testing.T) {
+	provider := fakeProvider{"laptop": sessionClaims("jti-laptop", "alice", time.Now())}
+
+	tests := []struct {
+		name     string
+		denylist Denylist
+		token    string
+		want     int
+	}{
+		{"no token", revocation.NewDenylist(revocation.NewMemory(), time.Hour), "", http.StatusUnauthorized},
+		{"invalid token", revocation.NewDenylist(revocation.NewMemory(), time.Hour), "forged", http.StatusUnauthorized},
+		{"denylist unavailable", failingDenylist{}, "laptop", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		router := newAuthRouter(NewMiddleware(provider, tt.denylist, nil))
+
+		if rec := authorize(router, tt.token); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
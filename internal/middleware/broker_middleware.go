@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"GatewayService/internal/handler/response"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type BrokerState interface {
+	Connected() bool
+	RetryAfter() time.Duration
+}
+
+// BrokerAvailability fails fast with 503 while the gateway is reconnecting
+// to the message broker instead of letting the request wait for a publish
+func BrokerAvailability(state BrokerState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !state.Connected() {
+			retryAfter := int(math.Ceil(state.RetryAfter().Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable,
+				response.BuildJSONResponse("Error", "Message broker is unavailable, try again later"))
+			return
+		}
+
+		c.Next()
+	}
+}
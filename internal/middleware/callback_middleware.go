@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"GatewayService/internal/handler/response"
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// CallbackSecretHeader carries the secret shared with the storage service
+const CallbackSecretHeader = "X-Callback-Secret"
+
+// CallbackSecret lets only callers knowing the shared secret through, the
+// storage service callbacks are not made on behalf of a user
+func CallbackSecret(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sent := c.GetHeader(CallbackSecretHeader)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(secret)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized,
+				response.BuildJSONResponse("Error", "Invalid callback secret"))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallbackSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		want       int
+	}{
+		{"matching secret", "s3cret", "s3cret", http.StatusOK},
+		{"wrong secret", "s3cret", "guess", http.StatusUnauthorized},
+		{"missing secret", "s3cret", "", http.StatusUnauthorized},
+		{"nothing configured", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		router := gin.New()
+		router.POST("/response/", CallbackSecret(tt.configured), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/response/", nil)
+		if tt.sent != "" {
+			req.Header.Set(CallbackSecretHeader, tt.sent)
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
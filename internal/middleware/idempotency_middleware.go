@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"GatewayService/internal/handler/response"
+	"GatewayService/internal/idempotency"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// set on responses replayed from an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// context key the handlers read the idempotency key from
+	IdempotencyKey = "idempotencyKey"
+
+	MaxIdempotencyKeyLength = 255
+
+	// largest body that is buffered to be hashed and replayed, store
+	// mutations are well below it
+	maxIdempotentBodySize = 1 << 20
+)
+
+type IdempotencyStore interface {
+	Begin(login, key, requestHash string) (*idempotency.Response, error)
+	Finish(login, key string, response idempotency.Response)
+	Abort(login, key string)
+}
+
+// Idempotency makes a mutation carrying an Idempotency-Key header safe to
+// retry: the first response is remembered and replayed for the same request,
+// while reusing the key for another request is rejected. It must run after
+// AccessTokenValidation, keys are scoped to the login
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > MaxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest,
+				response.BuildJSONResponse("Error", "Idempotency-Key must not be longer than 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge,
+				response.BuildJSONResponse("Error", "Request body is too large to be made idempotent"))
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, response.BuildJSONResponse("Error", "Failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		login := c.GetString("login")
+
+		stored, err := store.Begin(login, key, requestHash(c.Request, body))
+		switch {
+		case errors.Is(err, idempotency.ErrMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, response.BuildJSONResponse("Error", err.Error()))
+			return
+		case errors.Is(err, idempotency.ErrInFlight):
+			c.AbortWithStatusJSON(http.StatusConflict, response.BuildJSONResponse("Error", err.Error()))
+			return
+		case stored != nil:
+			for name, values := range stored.Header {
+				for _, value := range values {
+					c.Writer.Header().Add(name, value)
+				}
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(stored.StatusCode, stored.Header.Get("Content-Type"), stored.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Set(IdempotencyKey, key)
+
+		finished := false
+		defer func() {
+			// a panicking handler must not keep the key in flight
+			if !finished {
+				store.Abort(login, key)
+			}
+		}()
+
+		c.Next()
+
+		// server errors are not remembered so the client can retry them
+		if recorder.Status() >= http.StatusInternalServerError {
+			store.Abort(login, key)
+		} else {
+			store.Finish(login, key, idempotency.Response{
+				StatusCode: recorder.Status(),
+				Header:     recorder.Header().Clone(),
+				Body:       recorder.body.Bytes(),
+			})
+		}
+		finished = true
+	}
+}
+
+// requestHash identifies a request by its method, path and body
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the body written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"GatewayService/internal/idempotency"
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("login", "alice")
+	})
+	router.POST("/store", Idempotency(idempotency.NewStore(time.Minute)), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusAccepted, gin.H{"call": calls})
+	})
+
+	send := func(key string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/store", bytes.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	first := send("k1", []byte(`{"name":"Corner Shop"}`))
+	replayed := send("k1", []byte(`{"name":"Corner Shop"}`))
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if replayed.Code != first.Code || replayed.Body.String() != first.Body.String() {
+		t.Errorf("replay %d %s, first %d %s", replayed.Code, replayed.Body, first.Code, first.Body)
+	}
+	if replayed.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("replay is not marked")
+	}
+
+	if rec := send("k1", []byte(`{"name":"Bakery"}`)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another body: status %d, want 422", rec.Code)
+	}
+
+	if rec := send("k2", bytes.Repeat([]byte("x"), maxIdempotentBodySize+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", rec.Code)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,135 @@
+package operation
+
+import (
+	"GatewayService/internal/cleanup"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	Pending   Status = "pending"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+)
+
+var (
+	ErrNotFound  = errors.New("operation not found")
+	ErrCompleted = errors.New("operation is already completed")
+)
+
+type Operation struct {
+	ID        string          `json:"id"`
+	Action    string          `json:"action"`
+	StoreID   string          `json:"storeId,omitempty"`
+	VersionID string          `json:"versionId,omitempty"`
+	Status    Status          `json:"status"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Login     string          `json:"-"`
+}
+
+// Tracker keeps the state of the asynchronous store operations in memory
+// until their retention runs out
+type Tracker struct {
+	mu         sync.RWMutex
+	operations map[string]*Operation
+	retention  time.Duration
+}
+
+func NewTracker(retention time.Duration) *Tracker {
+	return &Tracker{
+		operations: make(map[string]*Operation),
+		retention:  retention,
+	}
+}
+
+func (t *Tracker) Start(login, action, storeId, versionId string) Operation {
+	now := time.Now().UTC()
+
+	op := &Operation{
+		ID:        uuid.NewString(),
+		Action:    action,
+		StoreID:   storeId,
+		VersionID: versionId,
+		Status:    Pending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Login:     login,
+	}
+
+	t.mu.Lock()
+	t.operations[op.ID] = op
+	t.mu.Unlock()
+
+	return *op
+}
+
+// Get returns the operation only to the login that started it
+func (t *Tracker) Get(id, login string) (Operation, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	op, ok := t.operations[id]
+	if !ok || op.Login != login {
+		return Operation{}, ErrNotFound
+	}
+
+	return *op, nil
+}
+
+// Complete records the result of a pending operation. The result of an
+// operation cannot be replaced once it is known
+func (t *Tracker) Complete(id string, result json.RawMessage, errMessage string) (Operation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	op, ok := t.operations[id]
+	if !ok {
+		return Operation{}, ErrNotFound
+	}
+	if op.Status != Pending {
+		return Operation{}, ErrCompleted
+	}
+
+	op.Status = Succeeded
+	if errMessage != "" {
+		op.Status = Failed
+	}
+	op.Result = result
+	op.Error = errMessage
+	op.UpdatedAt = time.Now().UTC()
+
+	return *op, nil
+}
+
+func (t *Tracker) Remove(id string) {
+	t.mu.Lock()
+	delete(t.operations, id)
+	t.mu.Unlock()
+}
+
+// Run evicts expired operations every interval until ctx is done
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	cleanup.Run(ctx, interval, func(now time.Time) {
+		t.evictExpired(now.UTC())
+	})
+}
+
+func (t *Tracker) evictExpired(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, op := range t.operations {
+		if now.Sub(op.UpdatedAt) > t.retention {
+			delete(t.operations, id)
+		}
+	}
+}
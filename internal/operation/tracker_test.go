@@ -0,0 +1,38 @@
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCompleteKeepsTheFirstResult(t *testing.T) {
+	tracker := NewTracker(time.Minute)
+
+	op := tracker.Start("alice", "create_store", "", "")
+
+	if _, err := tracker.Complete(op.ID, json.RawMessage(`{"id":"1"}`), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tracker.Complete(op.ID, nil, "overwritten"); !errors.Is(err, ErrCompleted) {
+		t.Fatalf("second completion: %v, want %v", err, ErrCompleted)
+	}
+
+	got, err := tracker.Get(op.ID, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != Succeeded || string(got.Result) != `{"id":"1"}` {
+		t.Errorf("operation is %s with %s, want the first result", got.Status, got.Result)
+	}
+}
+
+func TestCompleteUnknownOperation(t *testing.T) {
+	tracker := NewTracker(time.Minute)
+
+	if _, err := tracker.Complete("unknown", nil, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"GatewayService/internal/rabbit"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type Publisher interface {
+	Publish(exchange, routingKey string, msg amqp.Publishing) error
+}
+
+type BrokerState interface {
+	Connected() bool
+}
+
+type spooledMessage struct {
+	Exchange      string     `json:"exchange"`
+	RoutingKey    string     `json:"routingKey"`
+	MessageID     string     `json:"messageId"`
+	CorrelationID string     `json:"correlationId,omitempty"`
+	Type          string     `json:"type,omitempty"`
+	Timestamp     time.Time  `json:"timestamp"`
+	Headers       amqp.Table `json:"headers,omitempty"`
+	ContentType   string     `json:"contentType"`
+	DeliveryMode  uint8      `json:"deliveryMode"`
+	Body          []byte     `json:"body"`
+}
+
+// Outbox publishes straight to the broker while it is reachable and nothing is
+// waiting in the spool. Otherwise messages go to the spool and Run relays them
+// in order once the broker is back. Request-reply messages are never spooled,
+// their caller would stop waiting long before the relay gets to them
+type Outbox struct {
+	spool         *Spool
+	next          Publisher
+	broker        BrokerState
+	retryInterval time.Duration
+	logger        *zap.Logger
+
+	// direct publishes share the lock, the relay takes it exclusively so a
+	// direct publish never overtakes a spooled message
+	mu   sync.RWMutex
+	wake chan struct{}
+}
+
+func NewOutbox(spool *Spool, next Publisher, broker BrokerState, retryInterval time.Duration, logger *zap.Logger) *Outbox {
+	return &Outbox{
+		spool:         spool,
+		next:          next,
+		broker:        broker,
+		retryInterval: retryInterval,
+		logger:        logger,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+func (o *Outbox) Publish(exchange, routingKey string, msg amqp.Publishing) error {
+	if msg.ReplyTo != "" {
+		return o.next.Publish(exchange, routingKey, msg)
+	}
+
+	if msg.MessageId == "" {
+		msg.MessageId = uuid.NewString()
+	}
+
+	o.mu.RLock()
+	if o.spool.Empty() && o.broker.Connected() {
+		err := o.next.Publish(exchange, routingKey, msg)
+		o.mu.RUnlock()
+
+		// only a message that was never sent may be spooled, spooling one the
+		// broker might already hold (ErrConfirmLost) would deliver it twice
+		if !errors.Is(err, rabbit.ErrNotConnected) {
+			return err
+		}
+	} else {
+		o.mu.RUnlock()
+	}
+
+	data, err := json.Marshal(spooledMessage{
+		Exchange:      exchange,
+		RoutingKey:    routingKey,
+		MessageID:     msg.MessageId,
+		CorrelationID: msg.CorrelationId,
+		Type:          msg.Type,
+		Timestamp:     msg.Timestamp,
+		Headers:       msg.Headers,
+		ContentType:   msg.ContentType,
+		DeliveryMode:  msg.DeliveryMode,
+		Body:          msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	err = o.spool.Append(data)
+	o.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	o.logger.With(
+		zap.String("place", "Outbox"),
+		zap.String("messageId", msg.MessageId),
+	).Info("Broker unavailable, message spooled")
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (o *Outbox) Stats() Stats {
+	return o.spool.Stats()
+}
+
+// Run relays the spooled messages until ctx is done
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		record, err := o.spool.Peek()
+		switch {
+		case errors.Is(err, ErrSpoolEmpty):
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.wake:
+			}
+			continue
+		case err != nil:
+			o.logger.With(
+				zap.String("place", "Outbox"),
+				zap.Error(err),
+			).Error("Failed to read outbox spool")
+		case o.relay(record):
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(o.retryInterval):
+		}
+	}
+}
+
+// relay publishes a single record and reports whether the relay may go on
+// with the next one
+func (o *Outbox) relay(record Record) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.broker.Connected() {
+		return false
+	}
+
+	var msg spooledMessage
+	if err := json.Unmarshal(record.Data, &msg); err != nil {
+		o.logger.With(
+			zap.String("place", "Outbox"),
+			zap.Int64("offset", record.Offset),
+			zap.Error(err),
+		).Error("Dropping undecodable spooled message")
+		return o.claimed(record)
+	}
+
+	if err := o.spool.Claim(record); err != nil {
+		o.logger.With(
+			zap.String("place", "Outbox"),
+			zap.Error(err),
+		).Error("Failed to claim spooled message")
+		return false
+	}
+
+	err := o.next.Publish(msg.Exchange, msg.RoutingKey, amqp.Publishing{
+		ContentType:   msg.ContentType,
+		DeliveryMode:  msg.DeliveryMode,
+		MessageId:     msg.MessageID,
+		CorrelationId: msg.CorrelationID,
+		Type:          msg.Type,
+		Timestamp:     msg.Timestamp,
+		Headers:       msg.Headers,
+		Body:          msg.Body,
+	})
+
+	logger := o.logger.With(
+		zap.String("place", "Outbox"),
+		zap.String("messageId", msg.MessageID),
+	)
+
+	switch {
+	case err == nil:
+		logger.Info("Spooled message relayed")
+	case notSent(err):
+		logger.With(zap.Error(err)).Warn("Failed to relay spooled message, will retry")
+		if err := o.spool.Release(record); err != nil {
+			logger.With(zap.Error(err)).Error("Failed to release spooled message")
+		}
+		return false
+	case errors.Is(err, rabbit.ErrPublishReturned):
+		logger.Error("Spooled message cannot be routed and is dropped")
+	default:
+		// e.g. ErrConfirmTimeout or ErrConfirmLost, the broker may or may not
+		// have it and relaying again could duplicate it
+		logger.With(zap.Error(err)).Error("Spooled message was not confirmed and may be lost")
+	}
+
+	if err := o.spool.Compact(); err != nil {
+		logger.With(zap.Error(err)).Error("Failed to compact outbox spool")
+	}
+
+	return true
+}
+
+// notSent reports the publish errors after which the broker has definitely
+// not taken the message, so it can be sent again without a duplicate
+func notSent(err error) bool {
+	return errors.Is(err, rabbit.ErrNotConnected) ||
+		errors.Is(err, rabbit.ErrPublisherBusy) ||
+		errors.Is(err, rabbit.ErrPublishNacked)
+}
+
+func (o *Outbox) claimed(record Record) bool {
+	if err := o.spool.Claim(record); err != nil {
+		return false
+	}
+
+	return o.spool.Compact() == nil
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"GatewayService/internal/rabbit"
+	"errors"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type connectedBroker bool
+
+func (b connectedBroker) Connected() bool {
+	return bool(b)
+}
+
+// scriptedPublisher fails with the given errors in turn, then succeeds
+type scriptedPublisher struct {
+	errs  []error
+	calls int
+}
+
+func (p *scriptedPublisher) Publish(_, _ string, _ amqp.Publishing) error {
+	p.calls++
+
+	if len(p.errs) == 0 {
+		return nil
+	}
+
+	err := p.errs[0]
+	p.errs = p.errs[1:]
+
+	return err
+}
+
+func newTestOutbox(t *testing.T, next Publisher) (*Outbox, *Spool) {
+	t.Helper()
+
+	spool := openTestSpool(t, t.TempDir())
+
+	return NewOutbox(spool, next, connectedBroker(true), time.Millisecond, zap.NewNop()), spool
+}
+
+func TestPublishSpoolsOnlyUnsentMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		spooled bool
+	}{
+		{name: "not connected", err: rabbit.ErrNotConnected, spooled: true},
+		{name: "confirm lost", err: rabbit.ErrConfirmLost},
+		{name: "confirm timeout", err: rabbit.ErrConfirmTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, spool := newTestOutbox(t, &scriptedPublisher{errs: []error{tt.err}})
+
+			err := o.Publish("stores", "store.create", amqp.Publishing{Body: []byte("{}")})
+
+			if spooled := !spool.Empty(); spooled != tt.spooled {
+				t.Fatalf("spooled: got %v, want %v", spooled, tt.spooled)
+			}
+			if tt.spooled && err != nil {
+				t.Fatalf("spooled publish returned %v", err)
+			}
+			if !tt.spooled && !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRelayReleasesOnlyUnsentMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		released bool
+	}{
+		{name: "not connected", err: rabbit.ErrNotConnected, released: true},
+		{name: "publisher busy", err: rabbit.ErrPublisherBusy, released: true},
+		{name: "nacked", err: rabbit.ErrPublishNacked, released: true},
+		{name: "confirm lost", err: rabbit.ErrConfirmLost},
+		{name: "confirm timeout", err: rabbit.ErrConfirmTimeout},
+		{name: "returned", err: rabbit.ErrPublishReturned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &scriptedPublisher{errs: []error{rabbit.ErrNotConnected, tt.err}}
+			o, spool := newTestOutbox(t, next)
+
+			if err := o.Publish("stores", "store.create", amqp.Publishing{Body: []byte("{}")}); err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := spool.Peek()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cont := o.relay(record); cont == tt.released {
+				t.Fatalf("relay went on: %v", cont)
+			}
+
+			if released := !spool.Empty(); released != tt.released {
+				t.Fatalf("released: got %v, want %v", released, tt.released)
+			}
+
+			if tt.released {
+				record, err := spool.Peek()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !o.relay(record) || !spool.Empty() {
+					t.Fatal("released message was not relayed on retry")
+				}
+				if next.calls != 3 {
+					t.Fatalf("publishes: got %d, want 3", next.calls)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package outbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSpoolFull  = errors.New("outbox spool is full")
+	ErrSpoolEmpty = errors.New("outbox spool is empty")
+)
+
+const (
+	// a compaction copies the records not relayed yet to a log of the next
+	// generation, the cursor file names the generation in use
+	logFile    = "spool.%d.log"
+	logPattern = "spool.*.log"
+	cursorFile = "spool.cursor"
+
+	// the relayed prefix is compacted away once it takes this share of maxBytes
+	compactRatio = 4
+
+	// length, crc32 of the payload, enqueue time in unix nanoseconds
+	headerSize = 4 + 4 + 8
+)
+
+type Record struct {
+	Offset     int64
+	Next       int64
+	EnqueuedAt time.Time
+	Data       []byte
+}
+
+type Stats struct {
+	Depth    int
+	Bytes    int64
+	OldestAt time.Time
+	MaxBytes int64
+}
+
+// Spool is an append-only file of records plus a cursor file pointing at the
+// first record not relayed yet. Both are fsync'd on every change, so a record
+// acknowledged by Append survives a crash. maxBytes limits the records not
+// relayed yet; the relayed ones are compacted away
+type Spool struct {
+	dir      string
+	maxBytes int64
+
+	mu         sync.Mutex
+	log        *os.File
+	generation int64
+	size       int64
+	cursor     int64
+	depth      int
+}
+
+func OpenSpool(dir string, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+
+	if err := s.recover(); err != nil {
+		if s.log != nil {
+			s.log.Close()
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// recover loads the cursor, removes the logs of other generations left by a
+// crash in the middle of a compaction and drops a torn record left at the end
+// of the log by a crash in the middle of an append
+func (s *Spool) recover() error {
+	generation, cursor, err := s.readCursor()
+	if err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(s.logPath(generation), os.O_CREATE|os.O_RDWR, 0o640)
+	if err != nil {
+		return err
+	}
+	s.log = log
+	s.generation = generation
+
+	if err := s.removeStaleLogs(); err != nil {
+		return err
+	}
+
+	info, err := s.log.Stat()
+	if err != nil {
+		return err
+	}
+	if cursor > info.Size() {
+		return fmt.Errorf("spool cursor %d is past the end of the log", cursor)
+	}
+
+	offset := cursor
+	depth := 0
+	for {
+		record, err := s.readAt(offset)
+		if err != nil {
+			break
+		}
+		offset = record.Next
+		depth++
+	}
+
+	if offset < info.Size() {
+		if err := s.log.Truncate(offset); err != nil {
+			return err
+		}
+		if err := s.log.Sync(); err != nil {
+			return err
+		}
+	}
+
+	s.size = offset
+	s.cursor = cursor
+	s.depth = depth
+
+	return s.writeCursor(generation, cursor)
+}
+
+func (s *Spool) Append(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recordSize := int64(headerSize + len(data))
+	if s.size-s.cursor+recordSize > s.maxBytes {
+		return ErrSpoolFull
+	}
+
+	// keep the log itself within maxBytes too
+	if s.size+recordSize > s.maxBytes {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, recordSize)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(time.Now().UnixNano()))
+	copy(buf[headerSize:], data)
+
+	if _, err := s.log.WriteAt(buf, s.size); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+
+	s.size += recordSize
+	s.depth++
+
+	return nil
+}
+
+// Peek returns the oldest record not relayed yet
+func (s *Spool) Peek() (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.depth == 0 {
+		return Record{}, ErrSpoolEmpty
+	}
+
+	return s.readAt(s.cursor)
+}
+
+func (s *Spool) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.depth == 0
+}
+
+// Claim moves the cursor past record before it is relayed. A crash after
+// Claim never relays the record again, which may lose it but never duplicates it
+func (s *Spool) Claim(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.Offset != s.cursor {
+		return fmt.Errorf("claiming record at %d, cursor is at %d", record.Offset, s.cursor)
+	}
+
+	if err := s.writeCursor(s.generation, record.Next); err != nil {
+		return err
+	}
+	s.cursor = record.Next
+	s.depth--
+
+	return nil
+}
+
+// Release puts the cursor back on a claimed record the broker definitely did
+// not receive, so it is relayed again
+func (s *Spool) Release(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.Next != s.cursor {
+		return fmt.Errorf("releasing record ending at %d, cursor is at %d", record.Next, s.cursor)
+	}
+
+	if err := s.writeCursor(s.generation, record.Offset); err != nil {
+		return err
+	}
+	s.cursor = record.Offset
+	s.depth++
+
+	return nil
+}
+
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{
+		Depth:    s.depth,
+		Bytes:    s.size - s.cursor,
+		MaxBytes: s.maxBytes,
+	}
+
+	if s.depth > 0 {
+		if record, err := s.readAt(s.cursor); err == nil {
+			stats.OldestAt = record.EnqueuedAt
+		}
+	}
+
+	return stats
+}
+
+func (s *Spool) Close() error {
+	return s.log.Close()
+}
+
+// Compact drops the relayed records from the log once all of them have been
+// relayed or they take a share of maxBytes
+func (s *Spool) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cursor == 0 || (s.depth > 0 && s.cursor < s.maxBytes/compactRatio) {
+		return nil
+	}
+
+	return s.compact()
+}
+
+// compact copies the records not relayed yet to a log of the next generation.
+// Replacing the cursor file is the commit point: a crash before it keeps the
+// old log, a crash after it the new one, and recover removes the other
+func (s *Spool) compact() error {
+	generation := s.generation + 1
+
+	log, err := os.OpenFile(s.logPath(generation), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	pending := s.size - s.cursor
+	if _, err := io.Copy(log, io.NewSectionReader(s.log, s.cursor, pending)); err != nil {
+		log.Close()
+		return err
+	}
+	if err := log.Sync(); err != nil {
+		log.Close()
+		return err
+	}
+
+	if err := s.writeCursor(generation, 0); err != nil {
+		log.Close()
+		return err
+	}
+
+	old := s.log
+	s.log = log
+	s.generation = generation
+	s.size = pending
+	s.cursor = 0
+
+	old.Close()
+
+	return os.Remove(s.logPath(generation - 1))
+}
+
+func (s *Spool) readAt(offset int64) (Record, error) {
+	header := make([]byte, headerSize)
+	if _, err := s.log.ReadAt(header, offset); err != nil {
+		return Record{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	enqueuedAt := int64(binary.BigEndian.Uint64(header[8:16]))
+
+	if int64(length) > s.maxBytes {
+		return Record{}, fmt.Errorf("corrupted record header at offset %d", offset)
+	}
+
+	data := make([]byte, length)
+	if _, err := s.log.ReadAt(data, offset+headerSize); err != nil {
+		return Record{}, err
+	}
+
+	if crc32.ChecksumIEEE(data) != checksum {
+		return Record{}, fmt.Errorf("corrupted record at offset %d", offset)
+	}
+
+	return Record{
+		Offset:     offset,
+		Next:       offset + headerSize + int64(length),
+		EnqueuedAt: time.Unix(0, enqueuedAt).UTC(),
+		Data:       data,
+	}, nil
+}
+
+// readCursor returns the log generation and the cursor offset in it
+func (s *Spool) readCursor() (int64, int64, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, cursorFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var generation, cursor int64
+	if _, err := fmt.Sscanf(string(raw), "%d %d", &generation, &cursor); err != nil {
+		return 0, 0, fmt.Errorf("unreadable spool cursor %q: %w", raw, err)
+	}
+
+	return generation, cursor, nil
+}
+
+// writeCursor replaces the cursor file atomically
+func (s *Spool) writeCursor(generation, cursor int64) error {
+	tmp := filepath.Join(s.dir, cursorFile+".tmp")
+
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(file, "%d %d", generation, cursor); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(s.dir, cursorFile)); err != nil {
+		return err
+	}
+
+	// make the rename itself durable
+	dir, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+func (s *Spool) logPath(generation int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf(logFile, generation))
+}
+
+func (s *Spool) removeStaleLogs() error {
+	logs, err := filepath.Glob(filepath.Join(s.dir, logPattern))
+	if err != nil {
+		return err
+	}
+
+	current := s.logPath(s.generation)
+	for _, path := range logs {
+		if path == current {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
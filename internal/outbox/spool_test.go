@@ -0,0 +1,291 @@
+package outbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestSpool(t *testing.T, dir string) *Spool {
+	t.Helper()
+
+	return openSizedSpool(t, dir, 1<<20)
+}
+
+func openSizedSpool(t *testing.T, dir string, maxBytes int64) *Spool {
+	t.Helper()
+
+	s, err := OpenSpool(dir, maxBytes)
+	if err != nil {
+		t.Fatalf("OpenSpool: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func appendAll(t *testing.T, s *Spool, records ...string) {
+	t.Helper()
+
+	for _, record := range records {
+		if err := s.Append([]byte(record)); err != nil {
+			t.Fatalf("Append(%q): %v", record, err)
+		}
+	}
+}
+
+func peekData(t *testing.T, s *Spool) string {
+	t.Helper()
+
+	record, err := s.Peek()
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+
+	return string(record.Data)
+}
+
+// relay claims the next count records
+func relay(t *testing.T, s *Spool, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		record, err := s.Peek()
+		if err != nil {
+			t.Fatalf("Peek: %v", err)
+		}
+		if err := s.Claim(record); err != nil {
+			t.Fatalf("Claim: %v", err)
+		}
+	}
+}
+
+func logSize(t *testing.T, s *Spool) int64 {
+	t.Helper()
+
+	info, err := os.Stat(s.logPath(s.generation))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return info.Size()
+}
+
+func TestSpoolRecoverDropsTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestSpool(t, dir)
+	appendAll(t, s, "first", "second")
+	intact := s.Stats().Bytes
+	s.Close()
+
+	// a crash in the middle of an append leaves a header without its payload
+	log, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf(logFile, 0)), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Write([]byte{0, 0, 0, 9, 1, 2, 3, 4, 0, 0, 0, 0, 0, 0, 0, 1, 'x'}); err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+
+	s = openTestSpool(t, dir)
+
+	if stats := s.Stats(); stats.Depth != 2 || stats.Bytes != intact {
+		t.Fatalf("after recovery: depth %d, bytes %d, want 2 and %d", stats.Depth, stats.Bytes, intact)
+	}
+
+	if size := logSize(t, s); size != intact {
+		t.Fatalf("torn record not truncated: log has %d bytes, want %d", size, intact)
+	}
+
+	// records appended after the recovery follow the intact ones
+	appendAll(t, s, "third")
+	for _, want := range []string{"first", "second", "third"} {
+		record, err := s.Peek()
+		if err != nil {
+			t.Fatalf("Peek: %v", err)
+		}
+		if string(record.Data) != want {
+			t.Fatalf("got %q, want %q", record.Data, want)
+		}
+		if err := s.Claim(record); err != nil {
+			t.Fatalf("Claim: %v", err)
+		}
+	}
+}
+
+func TestSpoolCrashAfterClaimNeverRelaysAgain(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestSpool(t, dir)
+	appendAll(t, s, "first", "second")
+
+	record, err := s.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Claim(record); err != nil {
+		t.Fatal(err)
+	}
+	// crash while the claimed record is being published
+	s.Close()
+
+	s = openTestSpool(t, dir)
+
+	if depth := s.Stats().Depth; depth != 1 {
+		t.Fatalf("depth after restart: got %d, want 1", depth)
+	}
+	if got := peekData(t, s); got != "second" {
+		t.Fatalf("claimed record relayed again: got %q, want %q", got, "second")
+	}
+}
+
+func TestSpoolCrashDuringCompaction(t *testing.T) {
+	for _, committed := range []bool{false, true} {
+		dir := t.TempDir()
+
+		s := openTestSpool(t, dir)
+		appendAll(t, s, "first", "second")
+		relay(t, s, 1)
+
+		// the crash comes before the cursor file switches to the log of the
+		// next generation, or after it but before the old log is removed
+		leftover := s.logPath(s.generation + 1)
+		if committed {
+			leftover = s.logPath(s.generation)
+			if err := s.compact(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(leftover, []byte("partial copy"), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+
+		s = openTestSpool(t, dir)
+
+		if depth := s.Stats().Depth; depth != 1 {
+			t.Fatalf("committed %t: depth %d after recovery, want 1", committed, depth)
+		}
+		if got := peekData(t, s); got != "second" {
+			t.Fatalf("committed %t: got %q, want %q", committed, got, "second")
+		}
+
+		logs, err := filepath.Glob(filepath.Join(dir, logPattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) != 1 {
+			t.Fatalf("committed %t: logs left after recovery: %v", committed, logs)
+		}
+	}
+}
+
+func TestSpoolReleaseRelaysAgain(t *testing.T) {
+	s := openTestSpool(t, t.TempDir())
+	appendAll(t, s, "first", "second")
+
+	record, err := s.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Claim(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release(record); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := peekData(t, s); got != "first" {
+		t.Fatalf("after release: got %q, want %q", got, "first")
+	}
+	if depth := s.Stats().Depth; depth != 2 {
+		t.Fatalf("depth after release: got %d, want 2", depth)
+	}
+}
+
+func TestSpoolCompactOnlyWhenRelayed(t *testing.T) {
+	s := openTestSpool(t, t.TempDir())
+	appendAll(t, s, "first", "second")
+
+	relay(t, s, 1)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := peekData(t, s); got != "second" {
+		t.Fatalf("compact dropped a pending record: got %q", got)
+	}
+
+	relay(t, s, 1)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if size := logSize(t, s); size != 0 {
+		t.Fatalf("log not compacted: %d bytes", size)
+	}
+}
+
+func TestSpoolCompactsRelayedPrefix(t *testing.T) {
+	dir := t.TempDir()
+
+	// records of 20 bytes, the prefix is compacted from 50 bytes on
+	s := openSizedSpool(t, dir, 200)
+	appendAll(t, s, "0001", "0002", "0003", "0004")
+
+	relay(t, s, 2)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if size := logSize(t, s); size != 80 {
+		t.Fatalf("short prefix compacted: log has %d bytes, want 80", size)
+	}
+
+	relay(t, s, 1)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if size := logSize(t, s); size != 20 {
+		t.Fatalf("prefix not compacted: log has %d bytes, want 20", size)
+	}
+
+	record, err := s.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Offset != 0 || string(record.Data) != "0004" {
+		t.Fatalf("got %q at %d, want %q at 0", record.Data, record.Offset, "0004")
+	}
+
+	s.Close()
+	s = openSizedSpool(t, dir, 200)
+
+	if got := peekData(t, s); got != "0004" {
+		t.Fatalf("after reopening: got %q, want %q", got, "0004")
+	}
+}
+
+func TestSpoolLimitsPendingBytes(t *testing.T) {
+	s := openSizedSpool(t, t.TempDir(), 100)
+
+	appendAll(t, s, "0001", "0002", "0003", "0004", "0005")
+	if err := s.Append([]byte("0006")); err != ErrSpoolFull {
+		t.Fatalf("append past the limit: got %v, want %v", err, ErrSpoolFull)
+	}
+
+	// relayed records no longer count against the limit
+	relay(t, s, 2)
+	appendAll(t, s, "0006", "0007")
+
+	if size := logSize(t, s); size > 100 {
+		t.Errorf("log grew to %d bytes, limit is 100", size)
+	}
+	if got := peekData(t, s); got != "0003" {
+		t.Errorf("got %q, want %q", got, "0003")
+	}
+	if depth := s.Stats().Depth; depth != 5 {
+		t.Errorf("depth %d, want 5", depth)
+	}
+}
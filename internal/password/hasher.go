@@ -0,0 +1,164 @@
+// Package password hashes user passwords with argon2id into PHC strings,
+// bcrypt hashes of imported accounts are verified as well
+package password
+
+import (
+	"GatewayService/internal/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+var (
+	ErrUnsupportedHash = errors.New("unsupported password hash")
+	ErrMalformedHash   = errors.New("malformed password hash")
+)
+
+// bounds of the argon2id params, a stored hash beyond them is refused
+// rather than computed
+const (
+	minSaltLength = 8
+	minKeyLength  = 16
+	maxMemory     = 4 << 20 // 4 GiB
+	maxIterations = 64
+	maxKeyLength  = 1024
+)
+
+// argon2id params of a hash, memory in KiB
+type params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+// Hasher hashes passwords with the configured argon2id params and reports
+// the hashes made with other params or algorithms as outdated
+type Hasher struct {
+	params params
+}
+
+func NewHasher(cfg config.PasswordConfig) (*Hasher, error) {
+	p := params{
+		memory:      cfg.Memory,
+		iterations:  cfg.Iterations,
+		parallelism: cfg.Parallelism,
+		saltLength:  cfg.SaltLength,
+		keyLength:   cfg.KeyLength,
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Hasher{params: p}, nil
+}
+
+func (p params) validate() error {
+	if p.iterations < 1 || p.parallelism < 1 || p.memory < 8*uint32(p.parallelism) {
+		return errors.New("argon2id needs an iteration, a thread and 8 KiB of memory per thread at least")
+	}
+
+	if p.saltLength < minSaltLength || p.keyLength < minKeyLength {
+		return fmt.Errorf("argon2id needs %d byte salts and %d byte keys at least", minSaltLength, minKeyLength)
+	}
+
+	if p.memory > maxMemory || p.iterations > maxIterations || p.keyLength > maxKeyLength {
+		return fmt.Errorf("argon2id params are limited to %d KiB, %d iterations and %d byte keys", maxMemory, maxIterations, maxKeyLength)
+	}
+
+	return nil
+}
+
+// Hash returns the PHC string of the password,
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.iterations, h.params.memory, h.params.parallelism, h.params.keyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.memory, h.params.iterations, h.params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify compares the password with the hash in constant time. The second
+// result tells whether a matching hash should be replaced by a new one,
+// as it was made with other params or with bcrypt
+func (h *Hasher) Verify(password, hash string) (bool, bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return h.verifyArgon2id(password, hash)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+
+		return true, true, nil
+	}
+
+	return false, false, ErrUnsupportedHash
+}
+
+func (h *Hasher) verifyArgon2id(password, hash string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=2", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, ErrMalformedHash
+	}
+	if version != argon2.Version {
+		return false, false, ErrUnsupportedHash
+	}
+
+	var p params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return false, false, ErrMalformedHash
+	}
+	// Sscanf stops at the last verb, anything after it or leading zeros
+	// would make two strings of the same params
+	if parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", p.memory, p.iterations, p.parallelism) {
+		return false, false, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.Strict().DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.Strict().DecodeString(parts[5])
+	if err != nil {
+		return false, false, ErrMalformedHash
+	}
+
+	p.saltLength = uint32(len(salt))
+	p.keyLength = uint32(len(key))
+
+	if err := p.validate(); err != nil {
+		return false, false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+
+	return true, p != h.params, nil
+}
@@ -0,0 +1,168 @@
+package password
+
+import (
+	"GatewayService/internal/config"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+// testConfig keeps the tests fast, real configs use far more memory
+var testConfig = config.PasswordConfig{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newTestHasher(t *testing.T, cfg config.PasswordConfig) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func hash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+
+	encoded, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return encoded
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	h := newTestHasher(t, testConfig)
+
+	encoded := hash(t, h, "correct horse")
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %s does not carry its params", encoded)
+	}
+
+	ok, outdated, err := h.Verify("correct horse", encoded)
+	if err != nil || !ok || outdated {
+		t.Errorf("right password: %v, %v, %v", ok, outdated, err)
+	}
+
+	ok, _, err = h.Verify("wrong horse", encoded)
+	if err != nil || ok {
+		t.Errorf("wrong password: %v, %v", ok, err)
+	}
+
+	if other := hash(t, h, "correct horse"); other == encoded {
+		t.Error("two hashes of a password share their salt")
+	}
+}
+
+func TestHasherFlagsOtherParamsOutdated(t *testing.T) {
+	old := newTestHasher(t, testConfig)
+	encoded := hash(t, old, "correct horse")
+
+	changes := map[string]func(cfg *config.PasswordConfig){
+		"memory":      func(cfg *config.PasswordConfig) { cfg.Memory = 128 },
+		"iterations":  func(cfg *config.PasswordConfig) { cfg.Iterations = 2 },
+		"parallelism": func(cfg *config.PasswordConfig) { cfg.Parallelism = 2 },
+		"salt length": func(cfg *config.PasswordConfig) { cfg.SaltLength = 32 },
+		"key length":  func(cfg *config.PasswordConfig) { cfg.KeyLength = 64 },
+	}
+
+	for name, change := range changes {
+		cfg := testConfig
+		change(&cfg)
+
+		ok, outdated, err := newTestHasher(t, cfg).Verify("correct horse", encoded)
+		if err != nil || !ok || !outdated {
+			t.Errorf("%s changed: %v, %v, %v, want a match to rehash", name, ok, outdated, err)
+		}
+	}
+}
+
+func TestHasherVerifiesBcrypt(t *testing.T) {
+	h := newTestHasher(t, testConfig)
+
+	encoded, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		variant := prefix + string(encoded[4:])
+
+		ok, outdated, err := h.Verify("correct horse", variant)
+		if err != nil || !ok || !outdated {
+			t.Errorf("%s: %v, %v, %v, want a match to rehash", prefix, ok, outdated, err)
+		}
+
+		ok, _, err = h.Verify("wrong horse", variant)
+		if err != nil || ok {
+			t.Errorf("%s with a wrong password: %v, %v", prefix, ok, err)
+		}
+	}
+}
+
+func TestHasherRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, testConfig)
+
+	valid := hash(t, h, "correct horse")
+	parts := strings.Split(valid, "$")
+	salt, key := parts[4], parts[5]
+
+	withParams := func(params string) string {
+		return "$argon2id$v=19$" + params + "$" + salt + "$" + key
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		want error
+	}{
+		{"unknown algorithm", "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5", ErrUnsupportedHash},
+		{"plain text", "correct horse", ErrUnsupportedHash},
+		{"other version", strings.Replace(valid, "v=19", "v=16", 1), ErrUnsupportedHash},
+		{"missing part", "$argon2id$v=19$m=64,t=1,p=1$" + salt, ErrMalformedHash},
+		{"unreadable version", strings.Replace(valid, "v=19", "v=x", 1), ErrMalformedHash},
+		{"unreadable params", withParams("m=64;t=1;p=1"), ErrMalformedHash},
+		{"trailing params", withParams("m=64,t=1,p=1,x=2"), ErrMalformedHash},
+		{"leading zero", withParams("m=064,t=1,p=1"), ErrMalformedHash},
+		{"zero iterations", withParams("m=64,t=0,p=1"), ErrMalformedHash},
+		{"zero parallelism", withParams("m=64,t=1,p=0"), ErrMalformedHash},
+		{"too little memory", withParams("m=4,t=1,p=1"), ErrMalformedHash},
+		{"too much memory", withParams("m=4294967295,t=1,p=1"), ErrMalformedHash},
+		{"too many iterations", withParams("m=64,t=1000000,p=1"), ErrMalformedHash},
+		{"parallelism overflow", withParams("m=64,t=1,p=256"), ErrMalformedHash},
+		{"short salt", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$" + key, ErrMalformedHash},
+		{"short key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$a2V5", ErrMalformedHash},
+		{"padded salt", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "==$" + key, ErrMalformedHash},
+		{"bad bcrypt", "$2b$10$tooshort", ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		ok, _, err := h.Verify("correct horse", tt.hash)
+		if !errors.Is(err, tt.want) || ok {
+			t.Errorf("%s: %v, %v, want %v", tt.name, ok, err, tt.want)
+		}
+	}
+}
+
+func TestNewHasherRejectsParams(t *testing.T) {
+	changes := map[string]func(cfg *config.PasswordConfig){
+		"no iterations":       func(cfg *config.PasswordConfig) { cfg.Iterations = 0 },
+		"no threads":          func(cfg *config.PasswordConfig) { cfg.Parallelism = 0 },
+		"too little memory":   func(cfg *config.PasswordConfig) { cfg.Memory = 8; cfg.Parallelism = 2 },
+		"short salt":          func(cfg *config.PasswordConfig) { cfg.SaltLength = 4 },
+		"short key":           func(cfg *config.PasswordConfig) { cfg.KeyLength = 8 },
+		"too much memory":     func(cfg *config.PasswordConfig) { cfg.Memory = maxMemory + 1 },
+		"too many iterations": func(cfg *config.PasswordConfig) { cfg.Iterations = maxIterations + 1 },
+	}
+
+	for name, change := range changes {
+		cfg := testConfig
+		change(&cfg)
+
+		if _, err := NewHasher(cfg); err == nil {
+			t.Errorf("%s: config was accepted", name)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package provider
 
 import (
 	"GatewayService/internal/config"
+	"GatewayService/internal/jwtauth"
 	"fmt"
+	"github.com/golang-jwt/jwt/v4"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
@@ -67,10 +69,12 @@ func (p *AuthProvider) GetJWTToken(login string) (string, error) {
 	return tokenStr, nil
 }
 
-func (p *AuthProvider) ValidateToken(header string) error {
+// ValidateToken asks the auth generator whether the token is valid, the
+// claims of a token it accepted are read without verifying the signature again
+func (p *AuthProvider) ValidateToken(header string) (*jwtauth.Claims, error) {
 	req, err := http.NewRequest("GET", p.url+"/validate", nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header = http.Header{
@@ -79,18 +83,33 @@ func (p *AuthProvider) ValidateToken(header string) error {
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	resp.Body.Close()
 
 	if resp.StatusCode == http.StatusOK {
-		return nil
+		return validatedClaims(header)
 	} else if resp.StatusCode == http.StatusBadRequest {
-		return fmt.Errorf("token not found in header")
+		return nil, fmt.Errorf("token not found in header")
 	} else if resp.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("invalid or expired token")
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	return nil, fmt.Errorf("failed to validate token with status code %d", resp.StatusCode)
+}
+
+func validatedClaims(tokenStr string) (*jwtauth.Claims, error) {
+	claims := &jwtauth.Claims{}
+
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenStr, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Login == "" {
+		return nil, jwtauth.ErrMissingLogin
 	}
 
-	return fmt.Errorf("failed to validate token with status code %d", resp.StatusCode)
+	return claims, nil
 }
 
 func RetryConnection(repeat int, timeoutEach time.Duration, exec func() (interface{}, error)) (res interface{}, err error) {
@@ -0,0 +1,243 @@
+package rabbit
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/message"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// MessagePublisher is the Publisher itself or the outbox in front of it
+type MessagePublisher interface {
+	Publish(exchange, routingKey string, msg amqp.Publishing) error
+}
+
+// Bus is the RabbitMQ implementation of bus.MessageBus
+type Bus struct {
+	publisher MessagePublisher
+	router    *Router
+	replies   *ReplyConsumer
+	manager   *ConnectionManager
+	logger    *zap.Logger
+}
+
+func NewBus(publisher MessagePublisher, router *Router, replies *ReplyConsumer, manager *ConnectionManager, logger *zap.Logger) *Bus {
+	return &Bus{
+		publisher: publisher,
+		router:    router,
+		replies:   replies,
+		manager:   manager,
+		logger:    logger,
+	}
+}
+
+func (b *Bus) Publish(_ context.Context, envelope message.Envelope) error {
+	return b.send(envelope, "")
+}
+
+// Request publishes the envelope with the gateway reply queue as ReplyTo and
+// waits for the reply carrying its correlation ID
+func (b *Bus) Request(ctx context.Context, envelope message.Envelope) ([]byte, error) {
+	if envelope.CorrelationID == "" {
+		envelope.CorrelationID = uuid.NewString()
+	}
+
+	// before the first connection there is no reply queue, and a message
+	// without ReplyTo would be spooled like a fire-and-forget one
+	replyTo := b.replies.Queue()
+	if replyTo == "" {
+		return nil, ErrNotConnected
+	}
+
+	reply := b.replies.Register(envelope.CorrelationID)
+	defer b.replies.Cancel(envelope.CorrelationID)
+
+	if err := b.send(envelope, replyTo); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-reply:
+		if err := bus.ReplyErr(r.Status, r.Body); err != nil {
+			return nil, err
+		}
+		return r.Body, nil
+	case <-ctx.Done():
+		return nil, bus.ErrNoReply
+	}
+}
+
+// Subscribe consumes the action from an exclusive queue bound with the action
+// routing keys. It needs topic routing and is set up again on every reconnect
+func (b *Bus) Subscribe(action message.Action, handler bus.Handler) (bus.Subscription, error) {
+	exchange, keys, err := b.router.Bindings(action)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &subscription{
+		exchange: exchange,
+		keys:     keys,
+		action:   action,
+		handler:  handler,
+		logger:   b.logger,
+	}
+
+	if err := b.manager.Attach(sub.attach); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (b *Bus) Close() error {
+	b.manager.Close()
+	return nil
+}
+
+func (b *Bus) send(envelope message.Envelope, replyTo string) error {
+	body, err := envelope.Marshal()
+	if err != nil {
+		return err
+	}
+
+	exchange, routingKey, err := b.router.Route(envelope.Action, envelope.StoreID)
+	if err != nil {
+		return err
+	}
+
+	headers := amqp.Table{}
+	for key, value := range envelope.Headers() {
+		headers[key] = value
+	}
+
+	return b.publisher.Publish(
+		exchange,
+		routingKey,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			DeliveryMode:  amqp.Persistent,
+			MessageId:     envelope.MessageID,
+			CorrelationId: envelope.CorrelationID,
+			ReplyTo:       replyTo,
+			Type:          string(envelope.Action),
+			Timestamp:     envelope.IssuedAt,
+			Headers:       headers,
+			Body:          body,
+		},
+	)
+}
+
+type subscription struct {
+	exchange string
+	keys     []string
+	action   message.Action
+	handler  bus.Handler
+	logger   *zap.Logger
+
+	mu           sync.Mutex
+	channel      *amqp.Channel
+	unsubscribed bool
+}
+
+// attach is the ConnectHook of the subscription
+func (s *subscription) attach(conn Connection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unsubscribed {
+		return nil
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+
+	queue, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		channel.Close()
+		return err
+	}
+
+	for _, key := range s.keys {
+		if err := channel.QueueBind(queue.Name, key, s.exchange, false, nil); err != nil {
+			channel.Close()
+			return err
+		}
+	}
+
+	deliveries, err := channel.Consume(queue.Name, "", false, true, false, false, nil)
+	if err != nil {
+		channel.Close()
+		return err
+	}
+
+	s.channel = channel
+
+	go s.consume(channel, deliveries)
+
+	return nil
+}
+
+func (s *subscription) consume(channel *amqp.Channel, deliveries <-chan amqp.Delivery) {
+	for delivery := range deliveries {
+		logger := s.logger.With(
+			zap.String("place", "RabbitBus"),
+			zap.String("action", string(s.action)),
+			zap.String("messageId", delivery.MessageId),
+		)
+
+		var envelope message.Envelope
+		if err := json.Unmarshal(delivery.Body, &envelope); err != nil {
+			logger.With(zap.Error(err)).Warn("Dropping undecodable message")
+			_ = delivery.Nack(false, false)
+			continue
+		}
+
+		body, err := s.handler(context.Background(), envelope)
+		if err != nil && delivery.ReplyTo == "" {
+			logger.With(zap.Error(err)).Warn("Subscriber failed to process message")
+			_ = delivery.Nack(false, false)
+			continue
+		}
+
+		// a failed request is answered too, its caller should not wait
+		// for the reply timeout
+		if delivery.ReplyTo != "" {
+			if err != nil && !errors.Is(err, bus.ErrNotFound) {
+				logger.With(zap.Error(err)).Warn("Subscriber failed to process request")
+				body = []byte(err.Error())
+			}
+
+			err = channel.Publish("", delivery.ReplyTo, false, false, amqp.Publishing{
+				ContentType:   "application/json",
+				CorrelationId: delivery.CorrelationId,
+				Headers:       amqp.Table{bus.ReplyStatusHeader: bus.ReplyStatus(err)},
+				Body:          body,
+			})
+			if err != nil {
+				logger.With(zap.Error(err)).Error("Failed to send reply")
+			}
+		}
+
+		_ = delivery.Ack(false)
+	}
+}
+
+func (s *subscription) Unsubscribe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unsubscribed = true
+	if s.channel == nil {
+		return nil
+	}
+
+	return s.channel.Close()
+}
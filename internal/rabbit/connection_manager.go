@@ -0,0 +1,223 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+var ErrNotConnected = errors.New("not connected to the message broker")
+
+// Connection is the part of *amqp.Connection the manager and its hooks use
+type Connection interface {
+	Channel() (*amqp.Channel, error)
+	IsClosed() bool
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// ConnectHook is run on every fresh connection, e.g. to declare the queue
+// topology or to open the channels of a component
+type ConnectHook func(conn Connection) error
+
+// ConnectionManager keeps a single AMQP connection alive. When the broker
+// closes it, the manager redials with exponential backoff and runs the
+// registered hooks again so every component gets fresh channels
+type ConnectionManager struct {
+	url        string
+	dial       func(url string) (Connection, error)
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     *zap.Logger
+
+	// held while dialing so a hook attached meanwhile is not missed
+	hooksMu sync.Mutex
+	hooks   []ConnectHook
+
+	mu         sync.RWMutex
+	conn       Connection
+	closed     chan *amqp.Error
+	connected  bool
+	retryAfter time.Duration
+}
+
+func NewConnectionManager(url string, minBackoff, maxBackoff time.Duration, logger *zap.Logger) *ConnectionManager {
+	return &ConnectionManager{
+		url:        url,
+		dial:       dial,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		logger:     logger,
+		retryAfter: minBackoff,
+	}
+}
+
+// OnConnect registers a hook. Hooks run in registration order and are meant
+// to be registered before Connect is called
+func (m *ConnectionManager) OnConnect(hook ConnectHook) {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+
+	m.hooks = append(m.hooks, hook)
+}
+
+// Attach registers a hook and runs it at once if the manager is connected
+func (m *ConnectionManager) Attach(hook ConnectHook) error {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+
+	m.hooks = append(m.hooks, hook)
+
+	m.mu.RLock()
+	conn, connected := m.conn, m.connected
+	m.mu.RUnlock()
+
+	if !connected {
+		return nil
+	}
+
+	return hook(conn)
+}
+
+// Connect dials the broker and runs the hooks once
+func (m *ConnectionManager) Connect() error {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+
+	conn, err := m.dial(m.url)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range m.hooks {
+		if err := hook(conn); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	m.mu.Lock()
+	m.conn = conn
+	m.closed = closed
+	m.connected = true
+	m.retryAfter = m.minBackoff
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Run watches the connection and reconnects until ctx is done. If Connect
+// failed at startup, Run keeps dialing until the broker is reachable
+func (m *ConnectionManager) Run(ctx context.Context) {
+	for {
+		m.mu.RLock()
+		closed := m.closed
+		m.mu.RUnlock()
+
+		if closed == nil {
+			if !m.reconnect(ctx) {
+				return
+			}
+
+			m.logger.With(
+				zap.String("place", "ConnectionManager"),
+			).Info("RabbitMQ connection established")
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			m.Close()
+			return
+		case amqpErr := <-closed:
+			m.mu.Lock()
+			m.connected = false
+			m.mu.Unlock()
+
+			m.logger.With(
+				zap.String("place", "ConnectionManager"),
+				zap.Any("reason", amqpErr),
+			).Warn("RabbitMQ connection lost, reconnecting")
+
+			if !m.reconnect(ctx) {
+				return
+			}
+
+			m.logger.With(
+				zap.String("place", "ConnectionManager"),
+			).Info("RabbitMQ connection restored")
+		}
+	}
+}
+
+func (m *ConnectionManager) reconnect(ctx context.Context) bool {
+	backoff := m.minBackoff
+
+	for {
+		m.mu.Lock()
+		m.retryAfter = backoff
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		err := m.Connect()
+		if err == nil {
+			return true
+		}
+
+		m.logger.With(
+			zap.String("place", "ConnectionManager"),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		).Warn("Failed to reconnect to RabbitMQ")
+
+		backoff *= 2
+		if backoff > m.maxBackoff {
+			backoff = m.maxBackoff
+		}
+	}
+}
+
+func (m *ConnectionManager) Connected() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.connected
+}
+
+// RetryAfter is how long clients should wait before retrying while the
+// manager is reconnecting
+func (m *ConnectionManager) RetryAfter() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.retryAfter
+}
+
+func (m *ConnectionManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.connected = false
+	if m.conn != nil {
+		m.conn.Close()
+	}
+}
+
+func dial(url string) (Connection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
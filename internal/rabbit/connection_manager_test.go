@@ -0,0 +1,249 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeConnection struct {
+	mu     sync.Mutex
+	closes []chan *amqp.Error
+	closed bool
+}
+
+func (c *fakeConnection) Channel() (*amqp.Channel, error) {
+	return nil, errors.New("fake connection has no channels")
+}
+
+func (c *fakeConnection) IsClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+func (c *fakeConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closes = append(c.closes, receiver)
+
+	return receiver
+}
+
+func (c *fakeConnection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	return nil
+}
+
+// drop closes the connection the way a broker restart does
+func (c *fakeConnection) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, receiver := range c.closes {
+		receiver <- amqp.ErrClosed
+		close(receiver)
+	}
+	c.closes = nil
+}
+
+// fakeBroker hands out connections and fails the dials it is told to
+type fakeBroker struct {
+	mu          sync.Mutex
+	failures    int
+	backoffs    []time.Duration
+	connections []*fakeConnection
+	manager     *ConnectionManager
+}
+
+func (b *fakeBroker) dial(string) (Connection, error) {
+	retryAfter := b.manager.RetryAfter()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.backoffs = append(b.backoffs, retryAfter)
+
+	if b.failures > 0 {
+		b.failures--
+		return nil, errors.New("connection refused")
+	}
+
+	conn := &fakeConnection{}
+	b.connections = append(b.connections, conn)
+
+	return conn, nil
+}
+
+func (b *fakeBroker) last() *fakeConnection {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.connections[len(b.connections)-1]
+}
+
+func newFakeManager(minBackoff, maxBackoff time.Duration) (*ConnectionManager, *fakeBroker) {
+	m := NewConnectionManager("amqp://fake", minBackoff, maxBackoff, zap.NewNop())
+	broker := &fakeBroker{manager: m}
+	m.dial = broker.dial
+
+	return m, broker
+}
+
+func TestConnectionManagerRunsHooksInOrder(t *testing.T) {
+	m, _ := newFakeManager(time.Millisecond, time.Millisecond)
+
+	var order []string
+	m.OnConnect(func(Connection) error {
+		order = append(order, "topology")
+		return nil
+	})
+	m.OnConnect(func(Connection) error {
+		order = append(order, "publisher")
+		return nil
+	})
+
+	if err := m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order) != 2 || order[0] != "topology" || order[1] != "publisher" {
+		t.Errorf("hooks ran as %v", order)
+	}
+	if !m.Connected() {
+		t.Error("manager is not connected")
+	}
+}
+
+func TestConnectionManagerClosesConnectionOnFailedHook(t *testing.T) {
+	m, broker := newFakeManager(time.Millisecond, time.Millisecond)
+
+	m.OnConnect(func(Connection) error {
+		return errors.New("topology mismatch")
+	})
+
+	if err := m.Connect(); err == nil {
+		t.Fatal("connect with a failing hook returned no error")
+	}
+
+	if !broker.last().IsClosed() {
+		t.Error("connection of the failed attempt was left open")
+	}
+	if m.Connected() {
+		t.Error("manager reports a connection")
+	}
+}
+
+func TestConnectionManagerReconnectsWithBackoff(t *testing.T) {
+	m, broker := newFakeManager(time.Millisecond, 2*time.Millisecond)
+
+	attached := make(chan Connection, 2)
+	m.OnConnect(func(conn Connection) error {
+		attached <- conn
+		return nil
+	})
+
+	if err := m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	first := <-attached
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(stopped)
+	}()
+
+	broker.mu.Lock()
+	broker.failures = 2
+	broker.mu.Unlock()
+
+	first.(*fakeConnection).drop()
+
+	select {
+	case conn := <-attached:
+		if conn == first {
+			t.Error("hooks got the lost connection again")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("manager did not reconnect")
+	}
+
+	broker.mu.Lock()
+	backoffs := append([]time.Duration(nil), broker.backoffs[1:]...)
+	broker.mu.Unlock()
+
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 2 * time.Millisecond}
+	if len(backoffs) != len(want) {
+		t.Fatalf("redialed after %v, want %v", backoffs, want)
+	}
+	for i := range want {
+		if backoffs[i] != want[i] {
+			t.Errorf("redialed after %v, want %v", backoffs, want)
+			break
+		}
+	}
+
+	cancel()
+	<-stopped
+
+	if !broker.last().IsClosed() {
+		t.Error("connection is still open after Run returned")
+	}
+}
+
+func TestConnectionManagerCloseWithoutConnection(t *testing.T) {
+	m, _ := newFakeManager(time.Millisecond, time.Millisecond)
+
+	m.Close()
+
+	if m.Connected() {
+		t.Error("manager reports a connection")
+	}
+}
+
+func TestConnectionManagerRunConnectsAfterFailedStart(t *testing.T) {
+	m, broker := newFakeManager(time.Millisecond, time.Millisecond)
+
+	broker.failures = 2
+	if err := m.Connect(); err == nil {
+		t.Fatal("connect to an unreachable broker returned no error")
+	}
+
+	attached := make(chan Connection, 1)
+	m.OnConnect(func(conn Connection) error {
+		attached <- conn
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	select {
+	case <-attached:
+	case <-time.After(time.Second):
+		t.Fatal("manager never ran the hooks")
+	}
+
+	// the hooks run before the manager reports the connection
+	deadline := time.Now().Add(time.Second)
+	for !m.Connected() {
+		if time.Now().After(deadline) {
+			t.Fatal("manager never connected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
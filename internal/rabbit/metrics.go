@@ -0,0 +1,41 @@
+package rabbit
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// publisherMetrics is published on /debug/vars as rabbit_publisher
+var publisherMetrics = expvar.NewMap("rabbit_publisher")
+
+var poolWaitMax = newMaxDuration("pool_wait_max_us")
+
+func expvarInt(value int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(value)
+	return v
+}
+
+// maxDuration keeps the longest observed duration in publisherMetrics
+type maxDuration struct {
+	mu    sync.Mutex
+	max   time.Duration
+	value *expvar.Int
+}
+
+func newMaxDuration(name string) *maxDuration {
+	m := &maxDuration{value: new(expvar.Int)}
+	publisherMetrics.Set(name, m.value)
+	return m
+}
+
+func (m *maxDuration) Observe(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if d > m.max {
+		m.max = d
+		m.value.Set(d.Microseconds())
+	}
+}
@@ -0,0 +1,352 @@
+package rabbit
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPublishNacked   = errors.New("broker refused the message")
+	ErrPublishReturned = errors.New("broker could not route the message to any queue")
+	ErrConfirmTimeout  = errors.New("broker did not confirm the message in time")
+	// ErrConfirmLost is returned for messages that were sent, but whose channel
+	// closed before the confirm arrived. Like after ErrConfirmTimeout the
+	// broker may or may not have them, while ErrNotConnected means not sent
+	ErrConfirmLost   = errors.New("connection to the broker was lost before the message was confirmed")
+	ErrPublisherBusy = errors.New("no publisher channel became free in time")
+)
+
+// Publisher publishes mandatory messages in confirm mode and waits until the
+// broker has taken responsibility for each of them. amqp channels must not be
+// shared between goroutines, so every publish borrows a channel from a
+// bounded pool. The pool is rebuilt whenever the connection manager reconnects
+type Publisher struct {
+	size        int
+	timeout     time.Duration
+	waitTimeout time.Duration
+	logger      *zap.Logger
+
+	mu   sync.RWMutex
+	conn Connection
+	pool chan *confirmChannel
+}
+
+func NewPublisher(size int, confirmTimeout, waitTimeout time.Duration, logger *zap.Logger) *Publisher {
+	publisherMetrics.Set("pool_size", expvarInt(int64(size)))
+
+	return &Publisher{
+		size:        size,
+		timeout:     confirmTimeout,
+		waitTimeout: waitTimeout,
+		logger:      logger,
+	}
+}
+
+// Attach opens the pool channels on conn. It is meant to be registered
+// as a ConnectionManager hook
+func (p *Publisher) Attach(conn Connection) error {
+	pool := make(chan *confirmChannel, p.size)
+
+	for i := 0; i < p.size; i++ {
+		cc, err := p.openChannel(conn)
+		if err != nil {
+			close(pool)
+			for opened := range pool {
+				opened.channel.Close()
+			}
+			return err
+		}
+		pool <- cc
+	}
+
+	p.mu.Lock()
+	p.conn = conn
+	p.pool = pool
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *Publisher) openChannel(conn Connection) (*confirmChannel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := newConfirmChannel(channel, p.timeout)
+	if err != nil {
+		channel.Close()
+		return nil, err
+	}
+
+	return cc, nil
+}
+
+func (p *Publisher) Publish(exchange, routingKey string, msg amqp.Publishing) error {
+	p.mu.RLock()
+	conn, pool := p.conn, p.pool
+	p.mu.RUnlock()
+
+	if pool == nil {
+		return ErrNotConnected
+	}
+
+	cc, err := p.acquire(pool)
+	if err != nil {
+		return err
+	}
+
+	// a channel closed by a channel-level exception is replaced as long as
+	// the connection is still usable
+	if cc.isClosed() && !conn.IsClosed() {
+		fresh, err := p.openChannel(conn)
+		if err != nil {
+			p.logger.With(
+				zap.String("place", "Publisher"),
+				zap.Error(err),
+			).Error("Failed to reopen RabbitMQ channel")
+		} else {
+			cc = fresh
+		}
+	}
+
+	wait, err := cc.publish(exchange, routingKey, msg)
+
+	pool <- cc
+
+	if err != nil {
+		return err
+	}
+
+	return wait()
+}
+
+func (p *Publisher) acquire(pool chan *confirmChannel) (*confirmChannel, error) {
+	start := time.Now()
+	defer func() {
+		waited := time.Since(start)
+		publisherMetrics.Add("pool_wait_count", 1)
+		publisherMetrics.Add("pool_wait_total_us", waited.Microseconds())
+		poolWaitMax.Observe(waited)
+	}()
+
+	select {
+	case cc := <-pool:
+		return cc, nil
+	default:
+	}
+
+	timer := time.NewTimer(p.waitTimeout)
+	defer timer.Stop()
+
+	select {
+	case cc := <-pool:
+		return cc, nil
+	case <-timer.C:
+		publisherMetrics.Add("pool_wait_timeouts", 1)
+		return nil, ErrPublisherBusy
+	}
+}
+
+// amqpChannel is the part of *amqp.Channel a confirm channel publishes with
+type amqpChannel interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyReturn(c chan amqp.Return) chan amqp.Return
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+type pendingConfirm struct {
+	messageID string
+	returned  bool
+	result    chan error
+}
+
+// confirmChannel tracks the confirms of a single channel. Delivery tags are
+// scoped to the channel, so its state is dropped together with it.
+// Only the goroutine that borrowed it from the pool may publish on it
+type confirmChannel struct {
+	channel amqpChannel
+	timeout time.Duration
+
+	mu          sync.Mutex
+	nextTag     uint64
+	pending     map[uint64]*pendingConfirm
+	byMessageID map[string]uint64
+	closeErr    error
+}
+
+func newConfirmChannel(channel amqpChannel, timeout time.Duration) (*confirmChannel, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, err
+	}
+
+	cc := &confirmChannel{
+		channel:     channel,
+		timeout:     timeout,
+		pending:     make(map[uint64]*pendingConfirm),
+		byMessageID: make(map[string]uint64),
+	}
+
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 64))
+	returns := channel.NotifyReturn(make(chan amqp.Return, 64))
+
+	go cc.track(confirms, returns)
+
+	return cc, nil
+}
+
+func (cc *confirmChannel) isClosed() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	return cc.closeErr != nil
+}
+
+// publish sends msg and returns a function waiting for its confirm, so the
+// channel can go back to the pool before the broker answers
+func (cc *confirmChannel) publish(exchange, routingKey string, msg amqp.Publishing) (func() error, error) {
+	if msg.MessageId == "" {
+		msg.MessageId = uuid.NewString()
+	}
+
+	result := make(chan error, 1)
+
+	// the broker numbers only the messages actually sent, so the tag is
+	// taken for good once Publish succeeded. It is registered before, the
+	// confirm may arrive before Publish returns
+	cc.mu.Lock()
+	if cc.closeErr != nil {
+		cc.mu.Unlock()
+		// nothing is sent on a closed channel
+		return nil, ErrNotConnected
+	}
+	tag := cc.nextTag + 1
+	cc.pending[tag] = &pendingConfirm{messageID: msg.MessageId, result: result}
+	cc.byMessageID[msg.MessageId] = tag
+	cc.mu.Unlock()
+
+	err := cc.channel.Publish(exchange, routingKey, true, false, msg)
+	if err != nil {
+		cc.forget(tag)
+		if errors.Is(err, amqp.ErrClosed) {
+			return nil, ErrNotConnected
+		}
+		return nil, err
+	}
+
+	cc.mu.Lock()
+	cc.nextTag = tag
+	cc.mu.Unlock()
+
+	wait := func() error {
+		timer := time.NewTimer(cc.timeout)
+		defer timer.Stop()
+
+		select {
+		case err := <-result:
+			return err
+		case <-timer.C:
+			cc.forget(tag)
+			return ErrConfirmTimeout
+		}
+	}
+
+	return wait, nil
+}
+
+// track resolves the pending publishes. basic.return for an unroutable
+// message always arrives before its basic.ack, so returns are drained first
+func (cc *confirmChannel) track(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			cc.markReturned(ret.MessageId)
+		case confirm, ok := <-confirms:
+			if !ok {
+				cc.failAll(ErrConfirmLost)
+				return
+			}
+			cc.drainReturns(returns)
+			cc.resolve(confirm)
+		}
+	}
+}
+
+func (cc *confirmChannel) drainReturns(returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				return
+			}
+			cc.markReturned(ret.MessageId)
+		default:
+			return
+		}
+	}
+}
+
+func (cc *confirmChannel) markReturned(messageID string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if tag, ok := cc.byMessageID[messageID]; ok {
+		cc.pending[tag].returned = true
+	}
+}
+
+func (cc *confirmChannel) resolve(confirm amqp.Confirmation) {
+	cc.mu.Lock()
+	pending, ok := cc.pending[confirm.DeliveryTag]
+	if ok {
+		delete(cc.pending, confirm.DeliveryTag)
+		delete(cc.byMessageID, pending.messageID)
+	}
+	cc.mu.Unlock()
+
+	if !ok {
+		// the publisher already gave up waiting for it
+		return
+	}
+
+	switch {
+	case !confirm.Ack:
+		pending.result <- ErrPublishNacked
+	case pending.returned:
+		pending.result <- ErrPublishReturned
+	default:
+		pending.result <- nil
+	}
+}
+
+func (cc *confirmChannel) forget(tag uint64) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if pending, ok := cc.pending[tag]; ok {
+		delete(cc.pending, tag)
+		delete(cc.byMessageID, pending.messageID)
+	}
+}
+
+func (cc *confirmChannel) failAll(err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.closeErr = err
+	for tag, pending := range cc.pending {
+		pending.result <- err
+		delete(cc.pending, tag)
+		delete(cc.byMessageID, pending.messageID)
+	}
+}
@@ -0,0 +1,229 @@
+package rabbit
+
+import (
+	"errors"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChannel stands in for an amqp channel in confirm mode. Like the real
+// one it numbers only the messages it actually sent
+type fakeChannel struct {
+	mu         sync.Mutex
+	confirms   chan amqp.Confirmation
+	returns    chan amqp.Return
+	sent       []amqp.Publishing
+	publishErr error
+}
+
+func (f *fakeChannel) Confirm(bool) error { return nil }
+
+func (f *fakeChannel) NotifyPublish(confirms chan amqp.Confirmation) chan amqp.Confirmation {
+	f.confirms = confirms
+	return confirms
+}
+
+func (f *fakeChannel) NotifyReturn(returns chan amqp.Return) chan amqp.Return {
+	f.returns = returns
+	return returns
+}
+
+func (f *fakeChannel) Publish(_, _ string, _, _ bool, msg amqp.Publishing) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.publishErr; err != nil {
+		f.publishErr = nil
+		return err
+	}
+
+	f.sent = append(f.sent, msg)
+
+	return nil
+}
+
+func (f *fakeChannel) Close() error { return nil }
+
+func (f *fakeChannel) ack(tag uint64, ack bool) {
+	f.confirms <- amqp.Confirmation{DeliveryTag: tag, Ack: ack}
+}
+
+func (f *fakeChannel) lastMessageID() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.sent[len(f.sent)-1].MessageId
+}
+
+func newFakeConfirmChannel(t *testing.T, timeout time.Duration) (*confirmChannel, *fakeChannel) {
+	t.Helper()
+
+	fake := &fakeChannel{}
+	cc, err := newConfirmChannel(fake, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cc, fake
+}
+
+// publishAsync publishes and waits for the confirm in the background
+func publishAsync(t *testing.T, cc *confirmChannel, messageID string) <-chan error {
+	t.Helper()
+
+	wait, err := cc.publish("stores", "store.create", amqp.Publishing{MessageId: messageID})
+	if err != nil {
+		t.Fatalf("publish %s: %v", messageID, err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- wait()
+	}()
+
+	return result
+}
+
+func TestConfirmChannelResolvesConfirms(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm func(fake *fakeChannel)
+		want    error
+	}{
+		{"ack", func(fake *fakeChannel) {
+			fake.ack(1, true)
+		}, nil},
+		{"nack", func(fake *fakeChannel) {
+			fake.ack(1, false)
+		}, ErrPublishNacked},
+		{"return then ack", func(fake *fakeChannel) {
+			fake.returns <- amqp.Return{MessageId: fake.lastMessageID()}
+			fake.ack(1, true)
+		}, ErrPublishReturned},
+	}
+
+	for _, tt := range tests {
+		cc, fake := newFakeConfirmChannel(t, time.Second)
+
+		result := publishAsync(t, cc, "m1")
+		tt.confirm(fake)
+
+		if err := <-result; !errors.Is(err, tt.want) && err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestConfirmChannelTimesOut(t *testing.T) {
+	cc, fake := newFakeConfirmChannel(t, 20*time.Millisecond)
+
+	if err := <-publishAsync(t, cc, "m1"); !errors.Is(err, ErrConfirmTimeout) {
+		t.Fatalf("got %v, want %v", err, ErrConfirmTimeout)
+	}
+
+	// the late confirm must not resolve the next message
+	fake.ack(1, false)
+
+	result := publishAsync(t, cc, "m2")
+	fake.ack(2, true)
+
+	if err := <-result; err != nil {
+		t.Errorf("message after a timeout: %v", err)
+	}
+}
+
+func TestConfirmChannelKeepsTagsAfterFailedPublish(t *testing.T) {
+	cc, fake := newFakeConfirmChannel(t, time.Second)
+
+	fake.publishErr = errors.New("header value not supported")
+	if _, err := cc.publish("stores", "store.create", amqp.Publishing{MessageId: "m1"}); err == nil {
+		t.Fatal("failed publish returned no error")
+	}
+
+	// nothing was sent, so the broker confirms the next message with tag 1
+	result := publishAsync(t, cc, "m2")
+	fake.ack(1, true)
+
+	if err := <-result; err != nil {
+		t.Errorf("message after a failed publish: %v", err)
+	}
+}
+
+func TestConfirmChannelMapsClosedChannel(t *testing.T) {
+	cc, fake := newFakeConfirmChannel(t, time.Second)
+
+	fake.publishErr = amqp.ErrClosed
+	if _, err := cc.publish("stores", "store.create", amqp.Publishing{MessageId: "m1"}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("got %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func newFakePublisher(t *testing.T, size int, waitTimeout time.Duration) (*Publisher, []*fakeChannel) {
+	t.Helper()
+
+	p := NewPublisher(size, time.Second, waitTimeout, zap.NewNop())
+
+	pool := make(chan *confirmChannel, size)
+	fakes := make([]*fakeChannel, 0, size)
+	for i := 0; i < size; i++ {
+		cc, fake := newFakeConfirmChannel(t, time.Second)
+		pool <- cc
+		fakes = append(fakes, fake)
+	}
+	p.pool = pool
+
+	return p, fakes
+}
+
+func TestPublisherNotConnected(t *testing.T) {
+	p := NewPublisher(1, time.Second, time.Second, zap.NewNop())
+
+	if err := p.Publish("stores", "store.create", amqp.Publishing{}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("got %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func TestPublisherReturnsChannelToPool(t *testing.T) {
+	p, fakes := newFakePublisher(t, 1, time.Second)
+
+	for i := uint64(1); i <= 2; i++ {
+		result := make(chan error, 1)
+		go func() {
+			result <- p.Publish("stores", "store.create", amqp.Publishing{})
+		}()
+
+		// the single channel is released as soon as the message is sent
+		deadline := time.Now().Add(time.Second)
+		for {
+			fakes[0].mu.Lock()
+			sent := uint64(len(fakes[0].sent))
+			fakes[0].mu.Unlock()
+			if sent == i {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("message %d was never sent", i)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		fakes[0].ack(i, true)
+		if err := <-result; err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+}
+
+func TestPublisherBusy(t *testing.T) {
+	p, _ := newFakePublisher(t, 1, 20*time.Millisecond)
+
+	// every channel is borrowed
+	<-p.pool
+
+	if err := p.Publish("stores", "store.create", amqp.Publishing{}); !errors.Is(err, ErrPublisherBusy) {
+		t.Errorf("got %v, want %v", err, ErrPublisherBusy)
+	}
+}
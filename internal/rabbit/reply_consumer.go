@@ -0,0 +1,125 @@
+package rabbit
+
+import (
+	"GatewayService/internal/bus"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// ReplyConsumer owns the gateway reply queue and hands every reply
+// over to the request waiting for its correlation ID
+type ReplyConsumer struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	queue   string
+	pending map[string]chan Reply
+}
+
+// Reply is the body of a reply with its reply-status header
+type Reply struct {
+	Body   []byte
+	Status string
+}
+
+func NewReplyConsumer(logger *zap.Logger) *ReplyConsumer {
+	return &ReplyConsumer{
+		logger:  logger,
+		pending: make(map[string]chan Reply),
+	}
+}
+
+// Attach declares a fresh reply queue on conn and starts consuming it.
+// It is meant to be registered as a ConnectionManager hook
+func (rc *ReplyConsumer) Attach(conn Connection) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+
+	queue, err := channel.QueueDeclare(
+		"",    // name, generated by the broker
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		channel.Close()
+		return err
+	}
+
+	deliveries, err := channel.Consume(
+		queue.Name, // queue
+		"",         // consumer
+		true,       // auto-ack
+		true,       // exclusive
+		false,      // no-local
+		false,      // no-wait
+		nil,        // args
+	)
+	if err != nil {
+		channel.Close()
+		return err
+	}
+
+	rc.mu.Lock()
+	rc.queue = queue.Name
+	rc.mu.Unlock()
+
+	go rc.dispatch(deliveries)
+
+	return nil
+}
+
+// Queue returns the name of the queue the storage service should reply to
+func (rc *ReplyConsumer) Queue() string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.queue
+}
+
+// Register reserves a slot for the reply with the given correlation ID.
+// Cancel must be called once the caller stops waiting
+func (rc *ReplyConsumer) Register(correlationID string) <-chan Reply {
+	reply := make(chan Reply, 1)
+
+	rc.mu.Lock()
+	rc.pending[correlationID] = reply
+	rc.mu.Unlock()
+
+	return reply
+}
+
+func (rc *ReplyConsumer) Cancel(correlationID string) {
+	rc.mu.Lock()
+	delete(rc.pending, correlationID)
+	rc.mu.Unlock()
+}
+
+func (rc *ReplyConsumer) dispatch(deliveries <-chan amqp.Delivery) {
+	for delivery := range deliveries {
+		rc.mu.Lock()
+		reply, ok := rc.pending[delivery.CorrelationId]
+		delete(rc.pending, delivery.CorrelationId)
+		rc.mu.Unlock()
+
+		if !ok {
+			rc.logger.With(
+				zap.String("place", "ReplyConsumer"),
+				zap.String("correlationId", delivery.CorrelationId),
+			).Warn("Dropping reply nobody is waiting for")
+			continue
+		}
+
+		status, _ := delivery.Headers[bus.ReplyStatusHeader].(string)
+		reply <- Reply{Body: delivery.Body, Status: status}
+	}
+
+	rc.logger.With(
+		zap.String("place", "ReplyConsumer"),
+	).Warn("Reply queue consumer stopped")
+}
@@ -0,0 +1,97 @@
+package rabbit
+
+import (
+	"GatewayService/internal/bus"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestReplyConsumerDispatchesByCorrelationID(t *testing.T) {
+	rc := NewReplyConsumer(zap.NewNop())
+
+	first := rc.Register("first")
+	second := rc.Register("second")
+	cancelled := rc.Register("cancelled")
+	rc.Cancel("cancelled")
+
+	deliveries := make(chan amqp.Delivery, 4)
+	deliveries <- amqp.Delivery{CorrelationId: "second", Body: []byte("2")}
+	deliveries <- amqp.Delivery{CorrelationId: "unknown", Body: []byte("?")}
+	deliveries <- amqp.Delivery{CorrelationId: "cancelled", Body: []byte("x")}
+	deliveries <- amqp.Delivery{CorrelationId: "first", Body: []byte("1")}
+	close(deliveries)
+
+	rc.dispatch(deliveries)
+
+	for name, tt := range map[string]struct {
+		reply <-chan Reply
+		want  string
+	}{
+		"first":  {first, "1"},
+		"second": {second, "2"},
+	} {
+		select {
+		case r := <-tt.reply:
+			if string(r.Body) != tt.want {
+				t.Errorf("%s got %q, want %q", name, r.Body, tt.want)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s got no reply", name)
+		}
+	}
+
+	select {
+	case r := <-cancelled:
+		t.Errorf("cancelled request got %q", r.Body)
+	default:
+	}
+}
+
+func TestReplyConsumerDeliversOnce(t *testing.T) {
+	rc := NewReplyConsumer(zap.NewNop())
+
+	reply := rc.Register("id")
+
+	deliveries := make(chan amqp.Delivery, 2)
+	deliveries <- amqp.Delivery{CorrelationId: "id", Body: []byte("first")}
+	deliveries <- amqp.Delivery{CorrelationId: "id", Body: []byte("duplicate")}
+	close(deliveries)
+
+	// a duplicate must not block the dispatcher on the full reply slot
+	done := make(chan struct{})
+	go func() {
+		rc.dispatch(deliveries)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch blocked on a duplicate reply")
+	}
+
+	if r := <-reply; string(r.Body) != "first" {
+		t.Errorf("got %q, want the first reply", r.Body)
+	}
+}
+
+func TestReplyConsumerKeepsReplyStatus(t *testing.T) {
+	rc := NewReplyConsumer(zap.NewNop())
+
+	reply := rc.Register("id")
+
+	deliveries := make(chan amqp.Delivery, 1)
+	deliveries <- amqp.Delivery{
+		CorrelationId: "id",
+		Headers:       amqp.Table{bus.ReplyStatusHeader: bus.ReplyNotFound},
+	}
+	close(deliveries)
+
+	rc.dispatch(deliveries)
+
+	if r := <-reply; r.Status != bus.ReplyNotFound {
+		t.Errorf("status %q, want %q", r.Status, bus.ReplyNotFound)
+	}
+}
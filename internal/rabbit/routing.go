@@ -0,0 +1,105 @@
+package rabbit
+
+import (
+	"GatewayService/internal/config"
+	"GatewayService/internal/message"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+const (
+	QueueRouting = "queue"
+	TopicRouting = "topic"
+)
+
+var ErrNoRoute = errors.New("no routing key configured for action")
+
+// Router decides where a store action is published to
+type Router struct {
+	mode     string
+	queue    string
+	exchange string
+	shards   uint32
+	keys     map[message.Action]string
+}
+
+func NewRouter(cfg config.RoutingConfig, queue string) (*Router, error) {
+	router := &Router{
+		mode:     cfg.Mode,
+		queue:    queue,
+		exchange: cfg.Exchange,
+		keys:     make(map[message.Action]string, len(cfg.Keys)),
+	}
+
+	switch cfg.Mode {
+	case "", QueueRouting:
+		router.mode = QueueRouting
+		return router, nil
+	case TopicRouting:
+	default:
+		return nil, fmt.Errorf("unknown routing mode %q", cfg.Mode)
+	}
+
+	if cfg.Exchange == "" {
+		return nil, errors.New("topic routing needs an exchange")
+	}
+	if cfg.Shards < 0 {
+		return nil, fmt.Errorf("invalid shard count %d", cfg.Shards)
+	}
+	router.shards = uint32(cfg.Shards)
+
+	for action, key := range cfg.Keys {
+		router.keys[message.Action(action)] = key
+	}
+
+	return router, nil
+}
+
+// Route returns the exchange and routing key for action. In queue mode every
+// action goes to the stores queue through the default exchange
+func (r *Router) Route(action message.Action, storeId string) (string, string, error) {
+	if r.mode == QueueRouting {
+		return "", r.queue, nil
+	}
+
+	key, ok := r.keys[action]
+	if !ok {
+		return "", "", fmt.Errorf("%w %s", ErrNoRoute, action)
+	}
+
+	if r.shards > 0 && storeId != "" {
+		key += "." + strconv.FormatUint(uint64(shardOf(storeId, r.shards)), 10)
+	}
+
+	return r.exchange, key, nil
+}
+
+// Bindings returns the exchange and the binding keys receiving every message
+// of action, whatever shard it was routed to. Queue mode mixes all the actions
+// in one queue, so there is nothing to bind to
+func (r *Router) Bindings(action message.Action) (string, []string, error) {
+	if r.mode == QueueRouting {
+		return "", nil, fmt.Errorf("%w %s: queue routing has no per action bindings", ErrNoRoute, action)
+	}
+
+	key, ok := r.keys[action]
+	if !ok {
+		return "", nil, fmt.Errorf("%w %s", ErrNoRoute, action)
+	}
+
+	keys := []string{key}
+	if r.shards > 0 {
+		keys = append(keys, key+".*")
+	}
+
+	return r.exchange, keys, nil
+}
+
+func shardOf(storeId string, shards uint32) uint32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(storeId))
+
+	return hash.Sum32() % shards
+}
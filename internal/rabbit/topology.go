@@ -0,0 +1,144 @@
+package rabbit
+
+import (
+	"GatewayService/internal/config"
+	"errors"
+	"fmt"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	ClassicQueue = "classic"
+	QuorumQueue  = "quorum"
+)
+
+// TopologyError lists every declared entity whose definition differs
+// from the one already existing on the broker
+type TopologyError struct {
+	Mismatches []string
+}
+
+func (e *TopologyError) Error() string {
+	return "rabbit topology does not match the broker: " + strings.Join(e.Mismatches, "; ")
+}
+
+// ValidateTopology rejects definitions the broker would refuse anyway
+func ValidateTopology(topology config.TopologyConfig) error {
+	for _, queue := range topology.Queues {
+		switch queue.Type {
+		case "", ClassicQueue:
+		case QuorumQueue:
+			if !queue.Durable {
+				return fmt.Errorf("queue %q: quorum queues must be durable", queue.Name)
+			}
+		default:
+			return fmt.Errorf("queue %q: unknown queue type %q", queue.Name, queue.Type)
+		}
+	}
+
+	return nil
+}
+
+// DeclareTopology returns a ConnectHook declaring the configured exchanges,
+// queues and bindings. Declaring an entity that already exists with other
+// arguments fails with PRECONDITION_FAILED, which is reported as a mismatch
+func DeclareTopology(topology config.TopologyConfig, logger *zap.Logger) ConnectHook {
+	return func(conn Connection) error {
+		var mismatches []string
+
+		for _, exchange := range topology.Exchanges {
+			exchange := exchange
+			err := declare(conn, func(channel *amqp.Channel) error {
+				return channel.ExchangeDeclare(exchange.Name, exchange.Kind, exchange.Durable, exchange.AutoDelete, false, false, nil)
+			})
+			if mismatch, ok := asMismatch(err, "exchange", exchange.Name); ok {
+				mismatches = append(mismatches, mismatch)
+			} else if err != nil {
+				return fmt.Errorf("failed to declare exchange %q: %w", exchange.Name, err)
+			}
+		}
+
+		for _, queue := range topology.Queues {
+			queue := queue
+			err := declare(conn, func(channel *amqp.Channel) error {
+				_, err := channel.QueueDeclare(queue.Name, queue.Durable, false, false, false, queueArguments(queue))
+				return err
+			})
+			if mismatch, ok := asMismatch(err, "queue", queue.Name); ok {
+				mismatches = append(mismatches, mismatch)
+			} else if err != nil {
+				return fmt.Errorf("failed to declare queue %q: %w", queue.Name, err)
+			}
+		}
+
+		if len(mismatches) > 0 {
+			return &TopologyError{Mismatches: mismatches}
+		}
+
+		for _, binding := range topology.Bindings {
+			binding := binding
+			err := declare(conn, func(channel *amqp.Channel) error {
+				return channel.QueueBind(binding.Queue, binding.RoutingKey, binding.Exchange, false, nil)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to bind queue %q to exchange %q: %w", binding.Queue, binding.Exchange, err)
+			}
+		}
+
+		logger.With(
+			zap.String("place", "DeclareTopology"),
+			zap.Int("exchanges", len(topology.Exchanges)),
+			zap.Int("queues", len(topology.Queues)),
+			zap.Int("bindings", len(topology.Bindings)),
+		).Info("RabbitMQ topology verified")
+
+		return nil
+	}
+}
+
+// declare runs fn on a throwaway channel, as a failed declaration closes it
+func declare(conn Connection, fn func(channel *amqp.Channel) error) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	return fn(channel)
+}
+
+func asMismatch(err error, kind, name string) (string, bool) {
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.PreconditionFailed {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s %q: %s", kind, name, amqpErr.Reason), true
+}
+
+func queueArguments(queue config.QueueConfig) amqp.Table {
+	args := amqp.Table{}
+
+	if queue.Type != "" {
+		args["x-queue-type"] = queue.Type
+	}
+	if queue.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = queue.DeadLetterExchange
+	}
+	if queue.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = queue.DeadLetterRoutingKey
+	}
+	if queue.MessageTTL > 0 {
+		args["x-message-ttl"] = queue.MessageTTL.Milliseconds()
+	}
+	if queue.MaxLength > 0 {
+		args["x-max-length"] = queue.MaxLength
+	}
+	if queue.Overflow != "" {
+		args["x-overflow"] = queue.Overflow
+	}
+
+	return args
+}
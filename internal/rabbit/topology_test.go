@@ -0,0 +1,96 @@
+package rabbit
+
+import (
+	"GatewayService/internal/config"
+	"fmt"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestValidateTopology(t *testing.T) {
+	tests := []struct {
+		name    string
+		queue   config.QueueConfig
+		wantErr bool
+	}{
+		{"default type", config.QueueConfig{Name: "stores"}, false},
+		{"classic", config.QueueConfig{Name: "stores", Type: ClassicQueue}, false},
+		{"durable quorum", config.QueueConfig{Name: "stores", Type: QuorumQueue, Durable: true}, false},
+		{"transient quorum", config.QueueConfig{Name: "stores", Type: QuorumQueue}, true},
+		{"unknown type", config.QueueConfig{Name: "stores", Type: "stream"}, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTopology(config.TopologyConfig{Queues: []config.QueueConfig{tt.queue}})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestQueueArguments(t *testing.T) {
+	args := queueArguments(config.QueueConfig{
+		Type:                 QuorumQueue,
+		DeadLetterExchange:   "stores.dlx",
+		DeadLetterRoutingKey: "dead",
+		MessageTTL:           90 * time.Second,
+		MaxLength:            1000,
+		Overflow:             "reject-publish",
+	})
+
+	want := amqp.Table{
+		"x-queue-type":              QuorumQueue,
+		"x-dead-letter-exchange":    "stores.dlx",
+		"x-dead-letter-routing-key": "dead",
+		"x-message-ttl":             int64(90000),
+		"x-max-length":              int64(1000),
+		"x-overflow":                "reject-publish",
+	}
+
+	if len(args) != len(want) {
+		t.Fatalf("got %v, want %v", args, want)
+	}
+	for key, value := range want {
+		if args[key] != value {
+			t.Errorf("%s is %v, want %v", key, args[key], value)
+		}
+	}
+
+	if args := queueArguments(config.QueueConfig{Name: "stores"}); len(args) != 0 {
+		t.Errorf("plain queue got arguments %v", args)
+	}
+}
+
+func TestAsMismatch(t *testing.T) {
+	mismatch, ok := asMismatch(fmt.Errorf("declare: %w", &amqp.Error{
+		Code:   amqp.PreconditionFailed,
+		Reason: "inequivalent arg 'durable'",
+	}), "queue", "stores")
+	if !ok || mismatch != `queue "stores": inequivalent arg 'durable'` {
+		t.Errorf("got %q, %t", mismatch, ok)
+	}
+
+	if _, ok := asMismatch(&amqp.Error{Code: amqp.AccessRefused}, "queue", "stores"); ok {
+		t.Error("access refused reported as a mismatch")
+	}
+	if _, ok := asMismatch(nil, "queue", "stores"); ok {
+		t.Error("success reported as a mismatch")
+	}
+}
+
+func TestDeclareTopologyFailsWithoutChannel(t *testing.T) {
+	hook := DeclareTopology(config.TopologyConfig{
+		Exchanges: []config.ExchangeConfig{{Name: "stores", Kind: "topic", Durable: true}},
+	}, zap.NewNop())
+
+	if err := hook(&fakeConnection{}); err == nil {
+		t.Error("declaring on a broken connection returned no error")
+	}
+
+	// nothing to declare needs no channel
+	if err := DeclareTopology(config.TopologyConfig{}, zap.NewNop())(&fakeConnection{}); err != nil {
+		t.Errorf("empty topology: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package refresh
+
+import (
+	"GatewayService/internal/cleanup"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Store of a single gateway instance, the tokens are lost on restart
+type Memory struct {
+	mu           sync.Mutex
+	tokens       map[string]Token
+	families     map[string]time.Time
+	accessTokens map[string]familyAccessTokens
+}
+
+type familyAccessTokens struct {
+	ids   []string
+	until time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tokens:       make(map[string]Token),
+		families:     make(map[string]time.Time),
+		accessTokens: make(map[string]familyAccessTokens),
+	}
+}
+
+func (m *Memory) Save(_ context.Context, token Token) error {
+	m.mu.Lock()
+	m.tokens[token.ID] = token
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *Memory) Get(_ context.Context, id string) (Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[id]
+	if !ok || !time.Now().Before(token.ExpiresAt) {
+		return Token{}, ErrNotFound
+	}
+
+	return token, nil
+}
+
+func (m *Memory) Use(_ context.Context, id string) (Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[id]
+	if !ok || !time.Now().Before(token.ExpiresAt) {
+		return Token{}, ErrNotFound
+	}
+
+	used := token
+	used.Used = true
+	m.tokens[id] = used
+
+	return token, nil
+}
+
+func (m *Memory) RevokeFamily(_ context.Context, familyId string, until time.Time) error {
+	m.mu.Lock()
+	if until.After(m.families[familyId]) {
+		m.families[familyId] = until
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *Memory) FamilyRevoked(_ context.Context, familyId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.families[familyId]
+
+	return ok && time.Now().Before(until), nil
+}
+
+func (m *Memory) AddAccessToken(_ context.Context, familyId, tokenId string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family := m.accessTokens[familyId]
+	family.ids = append(family.ids, tokenId)
+	if until.After(family.until) {
+		family.until = until
+	}
+	m.accessTokens[familyId] = family
+
+	return nil
+}
+
+func (m *Memory) AccessTokens(_ context.Context, familyId string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family, ok := m.accessTokens[familyId]
+	if !ok || !time.Now().Before(family.until) {
+		return nil, nil
+	}
+
+	return append([]string(nil), family.ids...), nil
+}
+
+// Run evicts expired tokens and revocations every interval until ctx is done
+func (m *Memory) Run(ctx context.Context, interval time.Duration) {
+	cleanup.Run(ctx, interval, m.evictExpired)
+}
+
+func (m *Memory) evictExpired(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, token := range m.tokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(m.tokens, id)
+		}
+	}
+
+	for id, until := range m.families {
+		if !now.Before(until) {
+			delete(m.families, id)
+		}
+	}
+
+	for id, family := range m.accessTokens {
+		if !now.Before(family.until) {
+			delete(m.accessTokens, id)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func saveToken(t *testing.T, store Store, familyId string, ttl time.Duration) Token {
+	t.Helper()
+
+	_, id, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	token := Token{ID: id, FamilyID: familyId, Login: "alice", CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	if err := store.Save(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// testUseHasOneWinner uses one token concurrently, exactly one use may see
+// it unused
+func testUseHasOneWinner(t *testing.T, store Store) {
+	token := saveToken(t, store, "family", time.Minute)
+
+	const uses = 20
+
+	var wg sync.WaitGroup
+	results := make(chan Token, uses)
+	for i := 0; i < uses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			used, err := store.Use(context.Background(), token.ID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results <- used
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	winners := 0
+	for used := range results {
+		if !used.Used {
+			winners++
+		}
+	}
+	if winners != 1 {
+		t.Errorf("%d uses saw the token unused, want exactly one", winners)
+	}
+
+	stored, err := store.Get(context.Background(), token.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Used {
+		t.Error("token is not marked used")
+	}
+}
+
+func testExpiredToken(t *testing.T, store Store) {
+	token := saveToken(t, store, "family", 20*time.Millisecond)
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := store.Get(context.Background(), token.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get expired token: %v, want %v", err, ErrNotFound)
+	}
+	if _, err := store.Use(context.Background(), token.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("use expired token: %v, want %v", err, ErrNotFound)
+	}
+}
+
+func testFamilyRevocation(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if err := store.AddAccessToken(ctx, "family", "jti-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddAccessToken(ctx, "family", "jti-2", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddAccessToken(ctx, "other", "jti-3", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := store.AccessTokens(ctx, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("family has access tokens %v, want jti-1 and jti-2", ids)
+	}
+
+	if err := store.RevokeFamily(ctx, "family", time.Now().Add(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	for family, want := range map[string]bool{"family": true, "other": false} {
+		revoked, err := store.FamilyRevoked(ctx, family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != want {
+			t.Errorf("family %s revoked: %v, want %v", family, revoked, want)
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if revoked, _ := store.FamilyRevoked(ctx, "family"); revoked {
+		t.Error("family revocation outlived its time")
+	}
+}
+
+func TestMemoryUseHasOneWinner(t *testing.T) {
+	testUseHasOneWinner(t, NewMemory())
+}
+
+func TestMemoryExpiredToken(t *testing.T) {
+	testExpiredToken(t, NewMemory())
+}
+
+func TestMemoryFamilyRevocation(t *testing.T) {
+	testFamilyRevocation(t, NewMemory())
+}
+
+func TestMemoryEvictsExpired(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	saveToken(t, m, "family", time.Minute)
+	expired := saveToken(t, m, "family", time.Millisecond)
+	_ = m.RevokeFamily(ctx, "family", time.Now().Add(time.Millisecond))
+	_ = m.AddAccessToken(ctx, "family", "jti", time.Now().Add(time.Millisecond))
+
+	m.evictExpired(time.Now().Add(time.Second))
+
+	if _, ok := m.tokens[expired.ID]; ok || len(m.tokens) != 1 {
+		t.Errorf("tokens after eviction: %d, want the unexpired one", len(m.tokens))
+	}
+	if len(m.families) != 0 || len(m.accessTokens) != 0 {
+		t.Error("expired family records were kept")
+	}
+}
@@ -0,0 +1,141 @@
+package refresh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Redis is a Store shared by every gateway instance that outlives their
+// restarts. Keys expire together with the tokens and revocations they hold
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *Redis) Save(ctx context.Context, token Token) error {
+	value, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(token.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.client.Set(ctx, s.tokenKey(token.ID), value, ttl).Err()
+}
+
+func (s *Redis) Get(ctx context.Context, id string) (Token, error) {
+	value, err := s.client.Get(ctx, s.tokenKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Token{}, ErrNotFound
+	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	var token Token
+	if err := json.Unmarshal(value, &token); err != nil {
+		return Token{}, err
+	}
+
+	if !time.Now().Before(token.ExpiresAt) {
+		return Token{}, ErrNotFound
+	}
+
+	used, err := s.client.Exists(ctx, s.usedKey(id)).Result()
+	if err != nil {
+		return Token{}, err
+	}
+	token.Used = used > 0
+
+	return token, nil
+}
+
+func (s *Redis) Use(ctx context.Context, id string) (Token, error) {
+	token, err := s.Get(ctx, id)
+	if err != nil {
+		return Token{}, err
+	}
+
+	ttl := time.Until(token.ExpiresAt)
+
+	// the first SETNX wins, every later use finds the token used
+	first, err := s.client.SetNX(ctx, s.usedKey(id), 1, ttl).Result()
+	if err != nil {
+		return Token{}, err
+	}
+	token.Used = !first
+
+	return token, nil
+}
+
+func (s *Redis) RevokeFamily(ctx context.Context, familyId string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.client.Set(ctx, s.familyKey(familyId), 1, ttl).Err()
+}
+
+func (s *Redis) FamilyRevoked(ctx context.Context, familyId string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.familyKey(familyId)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// AddAccessToken keeps the IDs in a set living as long as the newest of them
+func (s *Redis) AddAccessToken(ctx context.Context, familyId, tokenId string, until time.Time) error {
+	if !time.Now().Before(until) {
+		return nil
+	}
+
+	key := s.accessTokensKey(familyId)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, tokenId)
+		pipe.ExpireAt(ctx, key, until)
+		return nil
+	})
+
+	return err
+}
+
+func (s *Redis) AccessTokens(ctx context.Context, familyId string) ([]string, error) {
+	return s.client.SMembers(ctx, s.accessTokensKey(familyId)).Result()
+}
+
+func (s *Redis) Close() error {
+	return s.client.Close()
+}
+
+func (s *Redis) tokenKey(id string) string {
+	return s.prefix + "token:" + id
+}
+
+func (s *Redis) usedKey(id string) string {
+	return s.prefix + "used:" + id
+}
+
+func (s *Redis) familyKey(familyId string) string {
+	return s.prefix + "family:" + familyId
+}
+
+func (s *Redis) accessTokensKey(familyId string) string {
+	return s.prefix + "access-tokens:" + familyId
+}
@@ -0,0 +1,69 @@
+// Package refresh keeps the refresh tokens the gateway hands out with access
+// tokens. Every rotation of a refresh token continues its family, reuse of a
+// rotated token revokes the whole family and the access tokens issued to it
+package refresh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	MemoryStore = "memory"
+	RedisStore  = "redis"
+)
+
+var ErrNotFound = errors.New("refresh token not found")
+
+// Token is a stored refresh token. Only the hash of the token itself is kept
+type Token struct {
+	ID        string    `json:"id"`
+	FamilyID  string    `json:"familyId"`
+	Login     string    `json:"login"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// set once the token was exchanged for a new one
+	Used bool `json:"used"`
+}
+
+// Store keeps refresh tokens until they expire
+type Store interface {
+	Save(ctx context.Context, token Token) error
+	Get(ctx context.Context, id string) (Token, error)
+	// Use marks the token used and returns it as it was before, so of
+	// concurrent uses exactly one sees Used unset
+	Use(ctx context.Context, id string) (Token, error)
+	// RevokeFamily revokes every token of the family issued until now,
+	// the revocation is kept until the given time
+	RevokeFamily(ctx context.Context, familyId string, until time.Time) error
+	FamilyRevoked(ctx context.Context, familyId string) (bool, error)
+	// AddAccessToken records the ID of an access token issued to the family,
+	// the record is kept until the given time
+	AddAccessToken(ctx context.Context, familyId, tokenId string, until time.Time) error
+	// AccessTokens returns the IDs of the access tokens issued to the family
+	AccessTokens(ctx context.Context, familyId string) ([]string, error)
+}
+
+// Generate returns a new random refresh token and the ID it is stored by
+func Generate() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return token, ID(token), nil
+}
+
+// ID is the ID a refresh token is stored by
+func ID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -3,24 +3,32 @@ package repository
 import (
 	"GatewayService/internal/service"
 	"errors"
+	"sync"
 )
 
 type MockUserRepository struct {
+	mu    sync.RWMutex
 	users []service.User
 }
 
+// NewMockUserRepository seeds the users password1, password2 and password3.
+// user2 has a hash of older argon2id params and user3 a bcrypt hash of an
+// imported account, both are rehashed on their next sign in
 func NewMockUserRepository() *MockUserRepository {
 	repo := &MockUserRepository{
 		users: []service.User{
-			{Login: "user1", Password: "password1"},
-			{Login: "user2", Password: "password2"},
-			{Login: "user3", Password: "password3"},
+			{Login: "user1", Password: "$argon2id$v=19$m=65536,t=3,p=2$c+OJv314kI9G+5Ycv20gUw$ATZR2pBb8fHzeft7W2Wz6R9XzF5OElR3e0zh9MY2kJA"},
+			{Login: "user2", Password: "$argon2id$v=19$m=19456,t=2,p=1$5wzBl8fidyKf1QGV3jr0mQ$skSnaal0n8EDzHdFh5t4sHQiuarZA85nuAOExr7pKPI"},
+			{Login: "user3", Password: "$2a$10$0ZZF/fdpq5GKYYZnOiyBPegfmAMTOc0GZCHo2cIYpisYf5dKfEmwy"},
 		},
 	}
 	return repo
 }
 
 func (r *MockUserRepository) GetUserByLogin(login string) (*service.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	for _, user := range r.users {
 		if user.Login == login {
 			return &user, nil
@@ -28,3 +36,16 @@ func (r *MockUserRepository) GetUserByLogin(login string) (*service.User, error)
 	}
 	return nil, errors.New("user not found")
 }
+
+func (r *MockUserRepository) UpdatePasswordHash(login, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.users {
+		if r.users[i].Login == login {
+			r.users[i].Password = hash
+			return nil
+		}
+	}
+	return errors.New("user not found")
+}
@@ -0,0 +1,74 @@
+// Package revocation keeps the access tokens revoked before they expire,
+// either one by one or every token issued to a login until some time
+package revocation
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	MemoryStore = "memory"
+	RedisStore  = "redis"
+)
+
+// Store keeps revocations until the given time, by then the revoked tokens
+// have expired anyway
+type Store interface {
+	RevokeToken(ctx context.Context, tokenId string, until time.Time) error
+	// RevokeLogin revokes the tokens of the login issued at or before issuedBefore
+	RevokeLogin(ctx context.Context, login string, issuedBefore, until time.Time) error
+	TokenRevoked(ctx context.Context, tokenId string) (bool, error)
+	// LoginRevokedBefore returns the zero time when no token of the login is revoked
+	LoginRevokedBefore(ctx context.Context, login string) (time.Time, error)
+}
+
+// Denylist revokes tokens in a Store for as long as tokens can live
+type Denylist struct {
+	store Store
+	// longest lifetime of a token, refresh tokens included
+	retention time.Duration
+}
+
+func NewDenylist(store Store, retention time.Duration) *Denylist {
+	return &Denylist{
+		store:     store,
+		retention: retention,
+	}
+}
+
+// RevokeToken revokes a single token, a zero expiresAt keeps the revocation
+// for the whole retention
+func (d *Denylist) RevokeToken(ctx context.Context, tokenId string, expiresAt time.Time) error {
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(d.retention)
+	}
+
+	return d.store.RevokeToken(ctx, tokenId, expiresAt)
+}
+
+// RevokeLogin revokes every token issued to the login until now
+func (d *Denylist) RevokeLogin(ctx context.Context, login string) error {
+	now := time.Now()
+
+	return d.store.RevokeLogin(ctx, login, now, now.Add(d.retention))
+}
+
+// Revoked reports whether the token was revoked by its ID or by its login.
+// A token without an issue time is revoked with every token of its login
+func (d *Denylist) Revoked(ctx context.Context, tokenId, login string, issuedAt time.Time) (bool, error) {
+	if tokenId != "" {
+		revoked, err := d.store.TokenRevoked(ctx, tokenId)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	before, err := d.store.LoginRevokedBefore(ctx, login)
+	if err != nil || before.IsZero() {
+		return false, err
+	}
+
+	// issue times are whole seconds, a token of the revocation second is revoked as well
+	return !issuedAt.After(before), nil
+}
@@ -0,0 +1,97 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDenylistRevokedByTokenID(t *testing.T) {
+	d := NewDenylist(NewMemory(), time.Hour)
+	ctx := context.Background()
+
+	if err := d.RevokeToken(ctx, "jti-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	for tokenId, want := range map[string]bool{"jti-1": true, "jti-2": false, "": false} {
+		revoked, err := d.Revoked(ctx, tokenId, "alice", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != want {
+			t.Errorf("token %q revoked: %v, want %v", tokenId, revoked, want)
+		}
+	}
+}
+
+func TestDenylistRevokedByLogin(t *testing.T) {
+	store := NewMemory()
+	d := NewDenylist(store, time.Hour)
+	ctx := context.Background()
+
+	// revoked in the middle of a second
+	before := time.Date(2024, 5, 1, 12, 0, 0, 700_000_000, time.UTC)
+	if err := store.RevokeLogin(ctx, "alice", before, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		login    string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"issued earlier", "alice", before.Add(-time.Hour).Truncate(time.Second), true},
+		// issue times are whole seconds, the token may be from after the
+		// revocation but is revoked with the others of its second
+		{"issued in the revocation second", "alice", before.Truncate(time.Second), true},
+		{"issued the next second", "alice", before.Truncate(time.Second).Add(time.Second), false},
+		{"no issue time", "alice", time.Time{}, true},
+		{"another login", "bob", before.Add(-time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		revoked, err := d.Revoked(ctx, "jti", tt.login, tt.issuedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != tt.want {
+			t.Errorf("%s: revoked %v, want %v", tt.name, revoked, tt.want)
+		}
+	}
+}
+
+func TestDenylistRevokeLoginKeepsLaterSessions(t *testing.T) {
+	d := NewDenylist(NewMemory(), time.Hour)
+	ctx := context.Background()
+
+	issuedBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	if err := d.RevokeLogin(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, _ := d.Revoked(ctx, "jti", "alice", issuedBefore); !revoked {
+		t.Error("token issued before the revocation is valid")
+	}
+
+	issuedAfter := time.Now().Truncate(time.Second).Add(time.Second)
+	if revoked, _ := d.Revoked(ctx, "jti", "alice", issuedAfter); revoked {
+		t.Error("token issued after the revocation is revoked")
+	}
+}
+
+func TestDenylistKeepsZeroExpiryForRetention(t *testing.T) {
+	store := NewMemory()
+	d := NewDenylist(store, time.Hour)
+
+	if err := d.RevokeToken(context.Background(), "jti", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	until := store.tokens["jti"]
+	if until.Before(time.Now().Add(59*time.Minute)) || until.After(time.Now().Add(time.Hour)) {
+		t.Errorf("revocation kept until %s, want the retention from now", until)
+	}
+}
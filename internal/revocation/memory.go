@@ -0,0 +1,96 @@
+package revocation
+
+import (
+	"GatewayService/internal/cleanup"
+	"context"
+	"sync"
+	"time"
+)
+
+type loginRevocation struct {
+	issuedBefore time.Time
+	until        time.Time
+}
+
+// Memory is a Store of a single gateway instance, the revocations are lost on restart
+type Memory struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	logins map[string]loginRevocation
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tokens: make(map[string]time.Time),
+		logins: make(map[string]loginRevocation),
+	}
+}
+
+func (m *Memory) RevokeToken(_ context.Context, tokenId string, until time.Time) error {
+	m.mu.Lock()
+	if until.After(m.tokens[tokenId]) {
+		m.tokens[tokenId] = until
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *Memory) RevokeLogin(_ context.Context, login string, issuedBefore, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revocation := m.logins[login]
+	if issuedBefore.After(revocation.issuedBefore) {
+		revocation.issuedBefore = issuedBefore
+	}
+	if until.After(revocation.until) {
+		revocation.until = until
+	}
+	m.logins[login] = revocation
+
+	return nil
+}
+
+func (m *Memory) TokenRevoked(_ context.Context, tokenId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.tokens[tokenId]
+
+	return ok && time.Now().Before(until), nil
+}
+
+func (m *Memory) LoginRevokedBefore(_ context.Context, login string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revocation, ok := m.logins[login]
+	if !ok || !time.Now().Before(revocation.until) {
+		return time.Time{}, nil
+	}
+
+	return revocation.issuedBefore, nil
+}
+
+// Run evicts expired revocations every interval until ctx is done
+func (m *Memory) Run(ctx context.Context, interval time.Duration) {
+	cleanup.Run(ctx, interval, m.evictExpired)
+}
+
+func (m *Memory) evictExpired(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, until := range m.tokens {
+		if !now.Before(until) {
+			delete(m.tokens, id)
+		}
+	}
+
+	for login, revocation := range m.logins {
+		if !now.Before(revocation.until) {
+			delete(m.logins, login)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testExpiry revokes a token and a login briefly, both revocations must be
+// gone once their time is over
+func testExpiry(t *testing.T, store Store) {
+	ctx := context.Background()
+	until := time.Now().Add(50 * time.Millisecond)
+	issuedBefore := time.Now()
+
+	if err := store.RevokeToken(ctx, "jti", until); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeLogin(ctx, "alice", issuedBefore, until); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := store.TokenRevoked(ctx, "jti"); err != nil || !revoked {
+		t.Fatalf("token revoked: %v, %v", revoked, err)
+	}
+	if before, err := store.LoginRevokedBefore(ctx, "alice"); err != nil || !before.Equal(issuedBefore) {
+		t.Fatalf("login revoked before %s, %v, want %s", before, err, issuedBefore)
+	}
+
+	time.Sleep(time.Until(until) + 20*time.Millisecond)
+
+	if revoked, err := store.TokenRevoked(ctx, "jti"); err != nil || revoked {
+		t.Errorf("token revocation outlived its time: %v, %v", revoked, err)
+	}
+	if before, err := store.LoginRevokedBefore(ctx, "alice"); err != nil || !before.IsZero() {
+		t.Errorf("login revocation outlived its time: %s, %v", before, err)
+	}
+}
+
+// testPastRevocation ignores revocations that are over already
+func testPastRevocation(t *testing.T, store Store) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Second)
+
+	if err := store.RevokeToken(ctx, "jti", past); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeLogin(ctx, "alice", past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, _ := store.TokenRevoked(ctx, "jti"); revoked {
+		t.Error("token revoked until the past is revoked")
+	}
+	if before, _ := store.LoginRevokedBefore(ctx, "alice"); !before.IsZero() {
+		t.Error("login revoked until the past is revoked")
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	testExpiry(t, NewMemory())
+}
+
+func TestMemoryPastRevocation(t *testing.T) {
+	testPastRevocation(t, NewMemory())
+}
+
+func TestMemoryKeepsLatestLoginRevocation(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	later := time.Now()
+	earlier := later.Add(-time.Minute)
+
+	_ = m.RevokeLogin(ctx, "alice", later, later.Add(time.Hour))
+	_ = m.RevokeLogin(ctx, "alice", earlier, later.Add(time.Minute))
+
+	revocation := m.logins["alice"]
+	if !revocation.issuedBefore.Equal(later) || !revocation.until.Equal(later.Add(time.Hour)) {
+		t.Errorf("revocation %+v was shortened", revocation)
+	}
+}
+
+func TestMemoryEvictsExpired(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	now := time.Now()
+
+	_ = m.RevokeToken(ctx, "expired", now.Add(time.Second))
+	_ = m.RevokeToken(ctx, "kept", now.Add(time.Hour))
+	_ = m.RevokeLogin(ctx, "alice", now, now.Add(time.Second))
+
+	m.evictExpired(now.Add(time.Minute))
+
+	if _, ok := m.tokens["expired"]; ok {
+		t.Error("expired token revocation was kept")
+	}
+	if _, ok := m.tokens["kept"]; !ok {
+		t.Error("current token revocation was evicted")
+	}
+	if len(m.logins) != 0 {
+		t.Error("expired login revocation was kept")
+	}
+}
@@ -0,0 +1,73 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Redis is a Store shared by every gateway instance, so a token revoked on
+// one instance is refused by all of them
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *Redis) RevokeToken(ctx context.Context, tokenId string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.client.Set(ctx, s.tokenKey(tokenId), 1, ttl).Err()
+}
+
+func (s *Redis) RevokeLogin(ctx context.Context, login string, issuedBefore, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.client.Set(ctx, s.loginKey(login), issuedBefore.UnixNano(), ttl).Err()
+}
+
+func (s *Redis) TokenRevoked(ctx context.Context, tokenId string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.tokenKey(tokenId)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *Redis) LoginRevokedBefore(ctx context.Context, login string) (time.Time, error) {
+	nanos, err := s.client.Get(ctx, s.loginKey(login)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, nanos), nil
+}
+
+func (s *Redis) Close() error {
+	return s.client.Close()
+}
+
+func (s *Redis) tokenKey(tokenId string) string {
+	return s.prefix + "token:" + tokenId
+}
+
+func (s *Redis) loginKey(login string) string {
+	return s.prefix + "login:" + login
+}
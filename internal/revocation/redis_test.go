@@ -0,0 +1,40 @@
+package revocation
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"testing"
+)
+
+// newTestRedis connects to the server at REDIS_ADDR, the tests are skipped
+// without one. Every test gets its own key prefix
+func newTestRedis(t *testing.T) *Redis {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+
+	s := NewRedis(client, "gateway-test:"+uuid.NewString()+":")
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	return s
+}
+
+func TestRedisExpiry(t *testing.T) {
+	testExpiry(t, newTestRedis(t))
+}
+
+func TestRedisPastRevocation(t *testing.T) {
+	testPastRevocation(t, newTestRedis(t))
+}
@@ -1,66 +1,308 @@
 package service
 
 import (
+	"GatewayService/internal/jwtauth"
+	"GatewayService/internal/refresh"
+	"context"
 	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"sync"
+	"time"
 )
 
 type UserRepository interface {
 	GetUserByLogin(login string) (*User, error)
+	// UpdatePasswordHash replaces the password hash of the user
+	UpdatePasswordHash(login, hash string) error
+}
+
+// PasswordHasher hashes passwords, Verify also reports whether a matching
+// hash is outdated and should be replaced
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) (bool, bool, error)
 }
 
 type AuthProvider interface {
 	GetJWTToken(login string) (string, error)
 }
 
+// User holds the password on sign in and the password hash in the repository
 type User struct {
 	Login    string
 	Password string
 }
 
+// Tokens are issued on sign in and on every refresh
+type Tokens struct {
+	AccessToken      string    `json:"accessToken"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// TokenRevoker revokes access tokens before they expire
+type TokenRevoker interface {
+	RevokeToken(ctx context.Context, tokenId string, expiresAt time.Time) error
+	RevokeLogin(ctx context.Context, login string) error
+	Revoked(ctx context.Context, tokenId, login string, issuedAt time.Time) (bool, error)
+}
+
 type AuthService struct {
-	provider   AuthProvider
-	logger     *zap.Logger
-	repository UserRepository
+	provider      AuthProvider
+	logger        *zap.Logger
+	repository    UserRepository
+	passwords     PasswordHasher
+	refreshTokens refresh.Store
+	refreshTTL    time.Duration
+	revocations   TokenRevoker
+
+	// verified against when the login is unknown, so the response time does
+	// not tell which logins exist
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthService(provider AuthProvider, logger *zap.Logger, repository UserRepository) *AuthService {
+func NewAuthService(provider AuthProvider, logger *zap.Logger, repository UserRepository, passwords PasswordHasher, refreshTokens refresh.Store, refreshTTL time.Duration, revocations TokenRevoker) *AuthService {
 	return &AuthService{
-		provider:   provider,
-		logger:     logger,
-		repository: repository,
+		provider:      provider,
+		logger:        logger,
+		repository:    repository,
+		passwords:     passwords,
+		refreshTokens: refreshTokens,
+		refreshTTL:    refreshTTL,
+		revocations:   revocations,
 	}
 }
 
 var (
-	ErrUserNotFound    = errors.New("user with provided login does not exist")
-	ErrInvalidPassword = errors.New("invalid password for user")
+	ErrUserNotFound        = errors.New("user with provided login does not exist")
+	ErrInvalidPassword     = errors.New("invalid password for user")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
 )
 
-func (s *AuthService) SignIn(credentials User) (string, error) {
-	user, err := s.repository.GetUserByLogin(credentials.Login)
+func (s *AuthService) SignIn(ctx context.Context, credentials User) (Tokens, error) {
+	user, _ := s.repository.GetUserByLogin(credentials.Login)
 	if user == nil {
-		return "", ErrUserNotFound
+		if hash := s.unknownLoginHash(); hash != "" {
+			_, _, _ = s.passwords.Verify(credentials.Password, hash)
+		}
+		return Tokens{}, ErrUserNotFound
 	}
 
-	isPasswordValid, err := checkPassword(credentials.Password, user.Password)
+	isPasswordValid, outdated, err := s.passwords.Verify(credentials.Password, user.Password)
+	if err != nil {
+		return Tokens{}, err
+	}
 	if !isPasswordValid {
-		return "", ErrInvalidPassword
+		return Tokens{}, ErrInvalidPassword
+	}
+
+	if outdated {
+		s.rehashPassword(credentials)
+	}
+
+	return s.issueTokens(ctx, credentials.Login, uuid.NewString())
+}
+
+// Refresh exchanges a refresh token for new tokens of the same family. A
+// token can be exchanged once, using it again revokes its whole family as
+// either the client or someone who stole the token holds a newer one.
+// The token is marked used only once its successors are saved, so a client
+// can retry a refresh that failed on the way
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	id := refresh.ID(refreshToken)
+
+	token, err := s.refreshTokens.Get(ctx, id)
+	if errors.Is(err, refresh.ErrNotFound) {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if token.Used {
+		return Tokens{}, s.refreshTokenReused(ctx, token)
+	}
+
+	revoked, err := s.refreshTokens.FamilyRevoked(ctx, token.FamilyID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if revoked {
+		return Tokens{}, ErrInvalidRefreshToken
 	}
 
-	accessToken, err := s.provider.GetJWTToken(credentials.Login)
+	// revoking the tokens of a login revokes its refresh tokens as well
+	revoked, err = s.revocations.Revoked(ctx, "", token.Login, token.CreatedAt)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if revoked {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	tokens, err := s.issueTokens(ctx, token.Login, token.FamilyID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	token, err = s.refreshTokens.Use(ctx, id)
+	if errors.Is(err, refresh.ErrNotFound) {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
 	if err != nil {
-		return "", err
+		return Tokens{}, err
 	}
 
-	return accessToken, nil
+	// a concurrent refresh with the same token got there first, the family
+	// is revoked together with the tokens both of them issued
+	if token.Used {
+		return Tokens{}, s.refreshTokenReused(ctx, token)
+	}
+
+	return tokens, nil
 }
 
-// there should be some hash logic implementation
-func checkPassword(password, hash string) (bool, error) {
-	if password == hash {
-		return true, nil
-	} else {
-		return false, nil
+// refreshTokenReused revokes the family of a token used a second time
+// together with the access tokens issued to it
+func (s *AuthService) refreshTokenReused(ctx context.Context, token refresh.Token) error {
+	// tokens of the family live refreshTTL at most, so does their revocation
+	if err := s.refreshTokens.RevokeFamily(ctx, token.FamilyID, time.Now().Add(s.refreshTTL)); err != nil {
+		return err
+	}
+
+	accessTokens, err := s.refreshTokens.AccessTokens(ctx, token.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	for _, tokenId := range accessTokens {
+		if err := s.revocations.RevokeToken(ctx, tokenId, time.Time{}); err != nil {
+			return err
+		}
 	}
+
+	s.logger.With(
+		zap.String("place", "authService"),
+		zap.String("login", token.Login),
+		zap.String("familyId", token.FamilyID),
+	).Warn("Refresh token reused, token family revoked")
+
+	return ErrRefreshTokenReused
+}
+
+// Logout revokes the access token of the login and, when given, the family
+// of its refresh token
+func (s *AuthService) Logout(ctx context.Context, login, tokenId string, expiresAt time.Time, refreshToken string) error {
+	if err := s.revocations.RevokeToken(ctx, tokenId, expiresAt); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	token, err := s.refreshTokens.Get(ctx, refresh.ID(refreshToken))
+	if errors.Is(err, refresh.ErrNotFound) || err == nil && token.Login != login {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.refreshTokens.RevokeFamily(ctx, token.FamilyID, time.Now().Add(s.refreshTTL))
+}
+
+// RevokeLogin revokes every access and refresh token issued to the login so far
+func (s *AuthService) RevokeLogin(ctx context.Context, login string) error {
+	return s.revocations.RevokeLogin(ctx, login)
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, login, familyId string) (Tokens, error) {
+	accessToken, err := s.provider.GetJWTToken(login)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	refreshToken, id, err := refresh.Generate()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	now := time.Now().UTC()
+
+	token := refresh.Token{
+		ID:        id,
+		FamilyID:  familyId,
+		Login:     login,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}
+
+	if err := s.refreshTokens.Save(ctx, token); err != nil {
+		return Tokens{}, err
+	}
+
+	// the family outlives its access tokens, so does the record of them
+	if err := s.refreshTokens.AddAccessToken(ctx, familyId, accessTokenID(accessToken), token.ExpiresAt); err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+// unknownLoginHash returns a hash made with the current params of a password
+// nobody knows, empty when hashing failed
+func (s *AuthService) unknownLoginHash() string {
+	s.dummyHashOnce.Do(func() {
+		hash, err := s.passwords.Hash(uuid.NewString())
+		if err != nil {
+			s.logger.With(zap.String("place", "authService")).Error("Failed to hash the unknown login password: " + err.Error())
+			return
+		}
+		s.dummyHash = hash
+	})
+
+	return s.dummyHash
+}
+
+// accessTokenID is the ID the auth middleware checks the revocation of an
+// access token by. The token comes straight from the provider, so its claims
+// are read without verifying the signature
+func accessTokenID(accessToken string) string {
+	claims := &jwtauth.Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, claims); err != nil {
+		claims = &jwtauth.Claims{}
+	}
+
+	return jwtauth.TokenID(accessToken, claims)
+}
+
+// rehashPassword replaces an outdated hash while the password is known,
+// signing in does not depend on it
+func (s *AuthService) rehashPassword(credentials User) {
+	logger := s.logger.With(
+		zap.String("place", "authService"),
+		zap.String("login", credentials.Login),
+	)
+
+	hash, err := s.passwords.Hash(credentials.Password)
+	if err != nil {
+		logger.Error("Failed to rehash password: " + err.Error())
+		return
+	}
+
+	if err := s.repository.UpdatePasswordHash(credentials.Login, hash); err != nil {
+		logger.Error("Failed to store rehashed password: " + err.Error())
+		return
+	}
+
+	logger.Info("Outdated password hash replaced")
 }
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"GatewayService/internal/jwtauth"
+	"GatewayService/internal/refresh"
+	"GatewayService/internal/revocation"
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+var errProviderDown = errors.New("auth provider is down")
+
+// flakyProvider fails the first failures calls
+type flakyProvider struct {
+	failures int
+}
+
+func (p *flakyProvider) GetJWTToken(login string) (string, error) {
+	if p.failures > 0 {
+		p.failures--
+		return "", errProviderDown
+	}
+
+	return "access-" + login, nil
+}
+
+// jwtProvider issues signed access tokens with a fresh jti each
+type jwtProvider struct{}
+
+func (jwtProvider) GetJWTToken(login string) (string, error) {
+	claims := jwtauth.Claims{
+		Login: login,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+}
+
+func newRefreshFixture(t *testing.T, provider AuthProvider) (*AuthService, string) {
+	s, _, refreshToken := newDenylistFixture(t, provider)
+
+	return s, refreshToken
+}
+
+func newDenylistFixture(t *testing.T, provider AuthProvider) (*AuthService, *revocation.Denylist, string) {
+	t.Helper()
+
+	tokens := refresh.NewMemory()
+	denylist := revocation.NewDenylist(revocation.NewMemory(), time.Hour)
+	s := NewAuthService(provider, zap.NewNop(), nil, nil, tokens, time.Hour, denylist)
+
+	refreshToken, id, err := refresh.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	err = tokens.Save(context.Background(), refresh.Token{
+		ID:        id,
+		FamilyID:  "family",
+		Login:     "alice",
+		CreatedAt: now.Add(-time.Minute),
+		ExpiresAt: now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, denylist, refreshToken
+}
+
+func TestRefreshCanBeRetriedAfterFailure(t *testing.T) {
+	s, refreshToken := newRefreshFixture(t, &flakyProvider{failures: 1})
+	ctx := context.Background()
+
+	if _, err := s.Refresh(ctx, refreshToken); !errors.Is(err, errProviderDown) {
+		t.Fatalf("first refresh: %v, want %v", err, errProviderDown)
+	}
+
+	tokens, err := s.Refresh(ctx, refreshToken)
+	if err != nil {
+		t.Fatalf("retried refresh: %v", err)
+	}
+
+	if _, err := s.Refresh(ctx, refreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("refresh with a rotated token: %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	// the reuse revoked the family, the token issued by the retry included
+	if _, err := s.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh in a revoked family: %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func accessTokenRevoked(t *testing.T, denylist *revocation.Denylist, accessToken string) bool {
+	t.Helper()
+
+	claims := &jwtauth.Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, claims); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := denylist.Revoked(context.Background(), claims.ID, claims.Login, claims.IssuedAt.Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return revoked
+}
+
+func TestRefreshReuseRevokesFamilyAccessTokens(t *testing.T) {
+	s, denylist, refreshToken := newDenylistFixture(t, jwtProvider{})
+	ctx := context.Background()
+
+	first, err := s.Refresh(ctx, refreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a session of another family
+	other, err := s.issueTokens(ctx, "alice", "other-family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Refresh(ctx, refreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("refresh with a rotated token: %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	for name, tokens := range map[string]Tokens{"first": first, "second": second} {
+		if !accessTokenRevoked(t, denylist, tokens.AccessToken) {
+			t.Errorf("access token of the %s refresh is still valid", name)
+		}
+	}
+	if accessTokenRevoked(t, denylist, other.AccessToken) {
+		t.Error("access token of another family was revoked")
+	}
+}
+
+func TestLogoutKeepsOtherSessions(t *testing.T) {
+	s, denylist, _ := newDenylistFixture(t, jwtProvider{})
+	ctx := context.Background()
+
+	laptop, err := s.issueTokens(ctx, "alice", "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, err := s.issueTokens(ctx, "alice", "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &jwtauth.Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(laptop.AccessToken, claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Logout(ctx, "alice", claims.ID, claims.ExpiresAt.Time, laptop.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if !accessTokenRevoked(t, denylist, laptop.AccessToken) {
+		t.Error("access token of the logged out session is valid")
+	}
+	if _, err := s.Refresh(ctx, laptop.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh of the logged out session: %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if accessTokenRevoked(t, denylist, phone.AccessToken) {
+		t.Error("access token of the other session was revoked")
+	}
+	if _, err := s.Refresh(ctx, phone.RefreshToken); err != nil {
+		t.Errorf("refresh of the other session: %v", err)
+	}
+}
+
+func TestLogoutRejectsRefreshTokenOfAnotherLogin(t *testing.T) {
+	s, _, _ := newDenylistFixture(t, jwtProvider{})
+	ctx := context.Background()
+
+	bob, err := s.issueTokens(ctx, "bob", "bob-family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Logout(ctx, "alice", "jti", time.Now().Add(time.Hour), bob.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("logout with the refresh token of bob: %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if _, err := s.Refresh(ctx, bob.RefreshToken); err != nil {
+		t.Errorf("refresh of bob after the rejected logout: %v", err)
+	}
+}
+
+// recordingHasher hashes passwords as "new:<password>" and accepts the
+// outdated "old:<password>" hashes as well. It records the hashes it verified
+type recordingHasher struct {
+	verified []string
+}
+
+func (h *recordingHasher) Hash(password string) (string, error) {
+	return "new:" + password, nil
+}
+
+func (h *recordingHasher) Verify(password, hash string) (bool, bool, error) {
+	h.verified = append(h.verified, hash)
+
+	switch hash {
+	case "new:" + password:
+		return true, false, nil
+	case "old:" + password:
+		return true, true, nil
+	}
+
+	return false, false, nil
+}
+
+type memoryUsers map[string]string
+
+func (u memoryUsers) GetUserByLogin(login string) (*User, error) {
+	hash, ok := u[login]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+
+	return &User{Login: login, Password: hash}, nil
+}
+
+func (u memoryUsers) UpdatePasswordHash(login, hash string) error {
+	u[login] = hash
+	return nil
+}
+
+func newSignInFixture(hasher *recordingHasher, users memoryUsers) *AuthService {
+	return NewAuthService(jwtProvider{}, zap.NewNop(), users, hasher, refresh.NewMemory(), time.Hour,
+		revocation.NewDenylist(revocation.NewMemory(), time.Hour))
+}
+
+func TestSignInVerifiesUnknownLogins(t *testing.T) {
+	hasher := &recordingHasher{}
+	s := newSignInFixture(hasher, memoryUsers{"alice": "new:secret"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.SignIn(context.Background(), User{Login: "mallory", Password: "guess"}); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("unknown login: %v, want %v", err, ErrUserNotFound)
+		}
+	}
+
+	// an unknown login costs a verification like a wrong password does
+	if len(hasher.verified) != 2 || hasher.verified[0] != hasher.verified[1] || hasher.verified[0] == "" {
+		t.Errorf("verified %q, want the same dummy hash twice", hasher.verified)
+	}
+
+	if _, err := s.SignIn(context.Background(), User{Login: "alice", Password: "guess"}); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("wrong password: %v, want %v", err, ErrInvalidPassword)
+	}
+}
+
+func TestSignInRehashesOutdatedPassword(t *testing.T) {
+	users := memoryUsers{"alice": "old:secret"}
+	s := newSignInFixture(&recordingHasher{}, users)
+
+	if _, err := s.SignIn(context.Background(), User{Login: "alice", Password: "guess"}); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("wrong password: %v, want %v", err, ErrInvalidPassword)
+	}
+	if users["alice"] != "old:secret" {
+		t.Fatal("hash was replaced on a failed sign in")
+	}
+
+	if _, err := s.SignIn(context.Background(), User{Login: "alice", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if users["alice"] != "new:secret" {
+		t.Errorf("hash is %q after signing in, want it rehashed", users["alice"])
+	}
+
+	if _, err := s.SignIn(context.Background(), User{Login: "alice", Password: "secret"}); err != nil {
+		t.Errorf("sign in with the new hash: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"GatewayService/internal/bus"
+	"GatewayService/internal/message"
+	"GatewayService/internal/operation"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+// OperationTracker follows the mutating actions until the storage service reports their result
+type OperationTracker interface {
+	Start(login, action, storeId, versionId string) operation.Operation
+	Remove(id string)
+}
+
+// StoresService sends the store actions of every API of the gateway to the
+// storage service
+type StoresService struct {
+	bus          bus.MessageBus
+	replyTimeout time.Duration
+	operations   OperationTracker
+	logger       *zap.Logger
+}
+
+func NewStoresService(messageBus bus.MessageBus, replyTimeout time.Duration, operations OperationTracker, logger *zap.Logger) *StoresService {
+	return &StoresService{
+		bus:          messageBus,
+		replyTimeout: replyTimeout,
+		operations:   operations,
+		logger:       logger,
+	}
+}
+
+// StartOperation starts an operation and publishes its action. The operation
+// is dropped again if the action could not be published
+func (s *StoresService) StartOperation(ctx context.Context, login string, action message.Action, storeId, versionId string, data interface{}, idempotencyKey string) (operation.Operation, error) {
+	op := s.operations.Start(login, string(action), storeId, versionId)
+
+	envelope, err := buildMessage(data, action, login, storeId, versionId, op.ID)
+	if err == nil {
+		envelope.IdempotencyKey = idempotencyKey
+		err = s.bus.Publish(ctx, envelope)
+	}
+
+	if err != nil {
+		s.operations.Remove(op.ID)
+		s.logger.With(
+			zap.String("place", "StoresService"),
+			zap.String("action", string(action)),
+			zap.Error(err),
+		).Error("Failed to publish a message")
+		return operation.Operation{}, err
+	}
+
+	return op, nil
+}
+
+// Request sends a read action and returns the storage service reply, or
+// bus.ErrNoReply if it does not arrive before the reply timeout
+func (s *StoresService) Request(ctx context.Context, login string, action message.Action, storeId, versionId string, data interface{}) ([]byte, error) {
+	envelope, err := buildMessage(data, action, login, storeId, versionId, "")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.replyTimeout)
+	defer cancel()
+
+	body, err := s.bus.Request(ctx, envelope)
+	if err != nil {
+		logger := s.logger.With(
+			zap.String("place", "StoresService"),
+			zap.String("action", string(action)),
+			zap.String("messageId", envelope.MessageID),
+			zap.Error(err),
+		)
+		if errors.Is(err, bus.ErrNoReply) {
+			logger.Warn("No reply from storage service")
+		} else {
+			logger.Error("Failed to publish a message")
+		}
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// buildMessage wraps data into the envelope of action. Mutations are
+// correlated by their operation ID
+func buildMessage(data interface{}, action message.Action, login, storeId, versionId, operationId string) (message.Envelope, error) {
+	envelope, err := message.NewEnvelope(action, login, storeId, versionId, data)
+	if err != nil {
+		return message.Envelope{}, err
+	}
+
+	envelope.OperationID = operationId
+	envelope.CorrelationID = operationId
+
+	return envelope, nil
+}